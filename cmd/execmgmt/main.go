@@ -42,7 +42,7 @@ var (
 
 	// simulation parameters
 	blocks, period, seeds, sdkGitRev string
-	genesis                          bool
+	genesis, importExport            bool
 
 	// ec2 instance properties
 	shutdownBehavior string
@@ -59,6 +59,7 @@ var (
 func init() {
 	flag.BoolVar(&genesis, "Genesis", false, "Use genesis file in simulation")
 	flag.BoolVar(&notifyOnly, "Notify", false, "Send notification and exit")
+	flag.BoolVar(&importExport, "ImportExport", false, "Run import/export round trips after every seed's simulation")
 
 	blocks = os.Getenv("BLOCKS")
 	period = os.Getenv("PERIOD")
@@ -267,19 +268,22 @@ func pushNotification(failed bool, message string) {
 
 func buildRunsimCommand(seeds, hostId, simId string) string {
 	logObjKey := fmt.Sprintf("sim-id-%s", os.Getenv("CIRCLE_BUILD_NUM"))
-	integration := "-Github"
+	options := "-Github"
 	if integrationType == slackIntegrationType {
-		integration = "-Slack"
+		options = "-Slack"
+	}
+	if importExport {
+		options += " -ImportExport"
 	}
 	if genesis {
 		return fmt.Sprintf("runsim -SimId %s -HostId %s -LogObjPrefix %s -SimAppPkg ./simapp %s -Seeds \"%s\" -Genesis %s %s %s TestFullAppSimulation;",
-			simId, hostId, logObjKey, integration, seeds, genesisFilePath, blocks, period)
+			simId, hostId, logObjKey, options, seeds, genesisFilePath, blocks, period)
 	}
 	log.Printf("runsim -SimId %s -HostId %s -LogObjPrefix %s -SimAppPkg ./simapp %s -Seeds \"%s\" %s %s TestFullAppSimulation;",
-		simId, hostId, logObjKey, integration, seeds, blocks, period)
+		simId, hostId, logObjKey, options, seeds, blocks, period)
 
 	return fmt.Sprintf("runsim -SimId %s -HostId %s -LogObjPrefix %s -SimAppPkg ./simapp %s -Seeds \"%s\" %s %s TestFullAppSimulation;",
-		simId, hostId, logObjKey, integration, seeds, blocks, period)
+		simId, hostId, logObjKey, options, seeds, blocks, period)
 }
 
 func buildInitMessage() string {
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// supported archive formats
+const (
+	archiveZip    = "zip"
+	archiveTarZst = "tar.zst"
+)
+
+const manifestName = "manifest.json"
+
+// the archiver is nil unless results are published, all methods are no-ops on a nil archiver
+var archiver *seedArchiver
+
+// seedArchiver adds the logs and exports of every seed to the archives as soon
+// as the seed completes, rather than compressing everything at the end of the
+// run. Entries are flushed after each seed, so a tar.zst archive can be read up
+// to the last archived seed even if runsim dies before closing it, though
+// without its manifest. A zip archive has its central directory and manifest
+// written on Close and can't be read if runsim dies before. A resumed run starts
+// new archives, holding the seeds finished before it as well as its own.
+type seedArchiver struct {
+	mtx      sync.Mutex
+	format   string
+	archives map[string]*archive
+}
+
+// archive is an archive file being written and the manifest of its content.
+type archive struct {
+	fileName string
+	file     *os.File
+	writer   archiveWriter
+	manifest archiveManifest
+}
+
+// archiveManifest is stored as manifest.json in every archive.
+type archiveManifest struct {
+	Seeds []manifestSeed `json:"seeds"`
+	// files not tied to a seed, e.g. the build log
+	Other []manifestFile `json:"other,omitempty"`
+}
+
+type manifestSeed struct {
+	Seed   int            `json:"seed"`
+	Cell   string         `json:"cell,omitempty"`
+	Result string         `json:"result"`
+	Files  []manifestFile `json:"files"`
+}
+
+type manifestFile struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+type archiveWriter interface {
+	create(name string, size int64, modTime time.Time) (io.Writer, error)
+	flush() error
+	close() error
+}
+
+func validateArchiveFormat(format string) error {
+	switch format {
+	case archiveZip, archiveTarZst:
+		return nil
+	}
+	return fmt.Errorf("unknown archive format %q, expected %q or %q", format, archiveZip, archiveTarZst)
+}
+
+func newSeedArchiver(format string) *seedArchiver {
+	return &seedArchiver{format: format, archives: make(map[string]*archive)}
+}
+
+// add archives the files of a completed seed according to its result.
+func (a *seedArchiver) add(seed Seed) {
+	if a == nil {
+		return
+	}
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	logs := okArchive
+	switch {
+	case seed.Failed:
+		logs = failedArchive
+	case seed.Interrupted:
+		logs = interruptedArchive
+	}
+	result := seed.status()
+	a.addSeed(logs, seed, result, seed.Stderr, seed.Stdout)
+	if len(seed.Runs) > 0 {
+		a.addSeed(logs, seed, result, seed.Runs...)
+	}
+	if len(seed.Stages) > 0 {
+		a.addSeed(logs, seed, result, seed.Stages...)
+	}
+	if len(seed.PrevAttempts) > 0 {
+		a.addSeed(failedArchive, seed, result, seed.PrevAttempts...)
+	}
+	a.addSeed(exportsArchive, seed, result, seed.ExportParams, seed.ExportState)
+}
+
+// addOther archives files that don't belong to a seed.
+func (a *seedArchiver) addOther(fileName string, files ...string) {
+	if a == nil {
+		return
+	}
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	arch, err := a.open(fileName)
+	if err != nil {
+		log.Printf("ERROR: archive %s: %v", fileName, err)
+		return
+	}
+	for _, file := range files {
+		entry, err := arch.addFile(file)
+		if err != nil {
+			log.Printf("ERROR: archive %s: %v", fileName, err)
+			continue
+		}
+		arch.manifest.Other = append(arch.manifest.Other, entry)
+	}
+	if err := arch.writer.flush(); err != nil {
+		log.Printf("ERROR: archive %s: %v", fileName, err)
+	}
+}
+
+// addSeed archives the files that exist among files, a.mtx must be held.
+func (a *seedArchiver) addSeed(fileName string, seed Seed, result string, files ...string) {
+	var existing []string
+	for _, file := range files {
+		// export files may not exist if the simulation failed before they are created
+		if _, err := os.Stat(file); err == nil {
+			existing = append(existing, file)
+		}
+	}
+	if len(existing) == 0 {
+		return
+	}
+
+	arch, err := a.open(fileName)
+	if err != nil {
+		log.Printf("ERROR: archive %s: %v", fileName, err)
+		return
+	}
+	entry := manifestSeed{Seed: seed.Num, Cell: seed.Cell, Result: result}
+	for _, file := range existing {
+		fileEntry, err := arch.addFile(file)
+		if err != nil {
+			log.Printf("ERROR: archive %s: %v", fileName, err)
+			continue
+		}
+		entry.Files = append(entry.Files, fileEntry)
+	}
+	arch.manifest.Seeds = append(arch.manifest.Seeds, entry)
+	if err := arch.writer.flush(); err != nil {
+		log.Printf("ERROR: archive %s: %v", fileName, err)
+	}
+}
+
+// open returns the archive, creating it on first use, a.mtx must be held.
+func (a *seedArchiver) open(fileName string) (*archive, error) {
+	if arch, ok := a.archives[fileName]; ok {
+		return arch, nil
+	}
+	file, err := os.Create(fileName)
+	if err != nil {
+		return nil, err
+	}
+	arch := &archive{fileName: fileName, file: file, manifest: archiveManifest{Seeds: []manifestSeed{}}}
+	if a.format == archiveTarZst {
+		arch.writer, err = newTarZstWriter(file)
+	} else {
+		arch.writer = zipWriter{zip.NewWriter(file)}
+	}
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	a.archives[fileName] = arch
+	return arch, nil
+}
+
+// Close writes the manifests and closes the archives, it returns the archives that were created.
+func (a *seedArchiver) Close() (fileNames []string, err error) {
+	if a == nil {
+		return
+	}
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	for _, fileName := range []string{okArchive, failedArchive, interruptedArchive, exportsArchive} {
+		arch, ok := a.archives[fileName]
+		if !ok {
+			continue
+		}
+		if err = arch.close(); err != nil {
+			return
+		}
+		fileNames = append(fileNames, fileName)
+	}
+	return
+}
+
+// addFile copies a file into the archive under its base name.
+func (arch *archive) addFile(fileName string) (entry manifestFile, err error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return
+	}
+	entry = manifestFile{Name: filepath.Base(fileName), Size: info.Size()}
+	writer, err := arch.writer.create(entry.Name, entry.Size, info.ModTime())
+	if err != nil {
+		return
+	}
+	hash := sha256.New()
+	if _, err = io.Copy(io.MultiWriter(writer, hash), io.LimitReader(file, entry.Size)); err != nil {
+		return
+	}
+	entry.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	return
+}
+
+func (arch *archive) close() (err error) {
+	defer func() {
+		cerr := arch.file.Close()
+		if err == nil {
+			err = cerr
+		}
+	}()
+
+	manifest, err := json.MarshalIndent(arch.manifest, "", "  ")
+	if err != nil {
+		return
+	}
+	writer, err := arch.writer.create(manifestName, int64(len(manifest)), time.Now())
+	if err != nil {
+		return
+	}
+	if _, err = writer.Write(manifest); err != nil {
+		return
+	}
+	return arch.writer.close()
+}
+
+type zipWriter struct {
+	*zip.Writer
+}
+
+func (w zipWriter) create(name string, size int64, modTime time.Time) (io.Writer, error) {
+	// deflate for better compression, see http://golang.org/pkg/archive/zip/#pkg-constants
+	return w.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modTime})
+}
+
+func (w zipWriter) flush() error {
+	return w.Flush()
+}
+
+func (w zipWriter) close() error {
+	return w.Close()
+}
+
+type tarZstWriter struct {
+	tar  *tar.Writer
+	zstd *zstd.Encoder
+}
+
+func newTarZstWriter(w io.Writer) (*tarZstWriter, error) {
+	enc, err := zstd.NewWriter(w)
+	if err != nil {
+		return nil, err
+	}
+	return &tarZstWriter{tar: tar.NewWriter(enc), zstd: enc}, nil
+}
+
+func (w *tarZstWriter) create(name string, size int64, modTime time.Time) (io.Writer, error) {
+	err := w.tar.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: size, ModTime: modTime})
+	return w.tar, err
+}
+
+func (w *tarZstWriter) flush() error {
+	if err := w.tar.Flush(); err != nil {
+		return err
+	}
+	return w.zstd.Flush()
+}
+
+func (w *tarZstWriter) close() error {
+	if err := w.tar.Close(); err != nil {
+		return err
+	}
+	return w.zstd.Close()
+}
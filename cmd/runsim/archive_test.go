@@ -0,0 +1,106 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSeedArchiver(t *testing.T) {
+	for _, format := range []string{archiveZip, archiveTarZst} {
+		t.Run(format, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "runsim-archive")
+			require.NoError(t, err)
+			defer os.RemoveAll(dir)
+
+			okArchive = filepath.Join(dir, "ok."+format)
+			failedArchive = filepath.Join(dir, "failed."+format)
+			interruptedArchive = filepath.Join(dir, "interrupted."+format)
+			exportsArchive = filepath.Join(dir, "exports."+format)
+
+			write := func(name, content string) string {
+				fileName := filepath.Join(dir, name)
+				require.NoError(t, ioutil.WriteFile(fileName, []byte(content), 0644))
+				return fileName
+			}
+			passed := Seed{Num: 1, Stdout: write("seed-1.stdout", "ok"), Stderr: write("seed-1.stderr", ""),
+				ExportState: write("sim_state-1.json", "{}"), ExportParams: filepath.Join(dir, "missing.json")}
+			failed := Seed{Num: 2, Stdout: write("seed-2.stdout", "panic"), Stderr: write("seed-2.stderr", "boom"), Failed: true}
+
+			a := newSeedArchiver(format)
+			a.add(passed)
+			a.add(failed)
+			archives, err := a.Close()
+			require.NoError(t, err)
+			require.Equal(t, []string{okArchive, failedArchive, exportsArchive}, archives)
+
+			files := readArchive(t, format, failedArchive)
+			require.Equal(t, "boom", files["seed-2.stderr"])
+			require.Equal(t, "panic", files["seed-2.stdout"])
+
+			var manifest archiveManifest
+			require.NoError(t, json.Unmarshal([]byte(files[manifestName]), &manifest))
+			require.Len(t, manifest.Seeds, 1)
+			require.Equal(t, 2, manifest.Seeds[0].Seed)
+			require.Equal(t, seedFailed, manifest.Seeds[0].Result)
+			require.Equal(t, []manifestFile{
+				{Name: "seed-2.stderr", Size: 4, SHA256: sha256Hex(files["seed-2.stderr"])},
+				{Name: "seed-2.stdout", Size: 5, SHA256: sha256Hex(files["seed-2.stdout"])},
+			}, manifest.Seeds[0].Files)
+
+			files = readArchive(t, format, exportsArchive)
+			require.Equal(t, "{}", files["sim_state-1.json"])
+			require.NotContains(t, files, "missing.json")
+		})
+	}
+}
+
+func sha256Hex(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+func readArchive(t *testing.T, format, fileName string) map[string]string {
+	files := make(map[string]string)
+	if format == archiveZip {
+		r, err := zip.OpenReader(fileName)
+		require.NoError(t, err)
+		defer r.Close()
+		for _, f := range r.File {
+			rc, err := f.Open()
+			require.NoError(t, err)
+			content, err := ioutil.ReadAll(rc)
+			require.NoError(t, err)
+			files[f.Name] = string(content)
+		}
+		return files
+	}
+
+	file, err := os.Open(fileName)
+	require.NoError(t, err)
+	defer file.Close()
+	dec, err := zstd.NewReader(file)
+	require.NoError(t, err)
+	defer dec.Close()
+	r := tar.NewReader(dec)
+	for {
+		header, err := r.Next()
+		if err == io.EOF {
+			return files
+		}
+		require.NoError(t, err)
+		content, err := ioutil.ReadAll(r)
+		require.NoError(t, err)
+		files[header.Name] = string(content)
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"syscall"
+)
+
+// runBisect implements "runsim bisect [flags] seed blocks period testname". It
+// looks for the smallest block count at which a failing seed still fails with
+// the same failure category, by binary search between 0 and blocks.
+func runBisect(tempDir string, args []string) {
+	if err := flag.CommandLine.Parse(args); err != nil {
+		log.Fatalf("ERROR: %v", err)
+	}
+	if flag.NArg() != 4 {
+		log.Fatal("ERROR: wrong number of arguments, expected seed blocks period testname")
+	}
+	seedNum, err := strconv.Atoi(flag.Arg(0))
+	if err != nil {
+		log.Fatalf("ERROR: invalid seed %q: %v", flag.Arg(0), err)
+	}
+	maxBlocks, err := strconv.Atoi(flag.Arg(1))
+	if err != nil || maxBlocks < 1 {
+		log.Fatalf("ERROR: invalid block count %q", flag.Arg(1))
+	}
+	period = flag.Arg(2)
+	testname = flag.Arg(3)
+
+	if err := initCmdTemplate(cmdTemplateText, cmdTemplateFile); err != nil {
+		log.Fatalf("ERROR: initCmdTemplate: %v", err)
+	}
+	// a single genesis file, or a directory holding one
+	cells, single, err := buildMatrix(genesis, flag.Arg(1))
+	if err == nil && cells != nil {
+		err = fmt.Errorf("bisect takes a single genesis file")
+	}
+	if err != nil {
+		log.Fatalf("ERROR: -Genesis: %v", err)
+	}
+	genesis = single
+	if precompile {
+		if err := precompileTestBinary(tempDir, filepath.Join(tempDir, "build_log")); err != nil {
+			log.Fatalf("ERROR: precompileTestBinary: %v", err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		sig := <-sigs
+		log.Printf("Received %s, stopping the bisection...", sig)
+		cancel()
+	}()
+
+	progress = newProgressTracker(1, 0)
+	run := func(numBlocks int) (Seed, error) {
+		blocks = strconv.Itoa(numBlocks)
+		seed := Seed{
+			Num:          seedNum,
+			Stderr:       filepath.Join(tempDir, fmt.Sprintf("%s-blocks-%d.stderr", buildLogFileName(seedNum), numBlocks)),
+			Stdout:       filepath.Join(tempDir, fmt.Sprintf("%s-blocks-%d.stdout", buildLogFileName(seedNum), numBlocks)),
+			ExportParams: filepath.Join(tempDir, fmt.Sprintf("sim_params-%d-blocks-%d.json", seedNum, numBlocks)),
+			ExportState:  filepath.Join(tempDir, fmt.Sprintf("sim_state-%d-blocks-%d.json", seedNum, numBlocks)),
+		}
+		return runSeed(ctx, 0, seed)
+	}
+
+	log.Printf("Bisecting seed %d between 0 and %d blocks, logs in %s", seedNum, maxBlocks, tempDir)
+	fail, failing, err := bisectBlocks(ctx, maxBlocks, run)
+	if ctx.Err() != nil {
+		os.Exit(1)
+	}
+	if err != nil {
+		log.Fatalf("Seed %d: %v", seedNum, err)
+	}
+
+	log.Printf("Seed %d fails with %s from %d blocks on", seedNum, failing.Failure.Category, fail)
+	if failing.Failure.Detail != "" {
+		log.Printf("Failure: %s", failing.Failure.Detail)
+	}
+	log.Printf("To reproduce run: %s",
+		buildCmdString(testname, strconv.Itoa(fail), period, genesis, failing.ExportState, failing.ExportParams, seedNum))
+}
+
+// bisectBlocks looks for the smallest block count up to maxBlocks at which run
+// fails with the failure category it fails with at maxBlocks, and returns the
+// block count and the seed of that run. Failing with another category counts
+// as passing.
+func bisectBlocks(ctx context.Context, maxBlocks int, run func(numBlocks int) (Seed, error)) (int, Seed, error) {
+	seed, err := run(maxBlocks)
+	switch {
+	case ctx.Err() != nil:
+		return 0, seed, ctx.Err()
+	case err == nil:
+		return 0, seed, fmt.Errorf("passes with %d blocks, nothing to bisect", maxBlocks)
+	case seed.Failure == nil:
+		// the simulation could not be run
+		return 0, seed, err
+	}
+	category := seed.Failure.Category
+	log.Printf("Blocks %d: FAILED (%s)", maxBlocks, category)
+
+	// pass is the largest block count known not to reproduce the failure, fail the smallest known to
+	pass, fail, failing := 0, maxBlocks, seed
+	for fail-pass > 1 {
+		mid := pass + (fail-pass)/2
+		seed, err := run(mid)
+		switch {
+		case ctx.Err() != nil:
+			return 0, seed, ctx.Err()
+		case err == nil:
+			log.Printf("Blocks %d: passed", mid)
+			pass = mid
+		case seed.Failure == nil:
+			return 0, seed, err
+		case seed.Failure.Category != category:
+			log.Printf("Blocks %d: FAILED with a different failure (%s)", mid, seed.Failure.Category)
+			pass = mid
+		default:
+			log.Printf("Blocks %d: FAILED (%s)", mid, category)
+			fail, failing = mid, seed
+		}
+	}
+	return fail, failing, nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// blocksRun fails a seed with the category its failures map gives the
+// smallest failing block count not above the one run, and passes it below.
+func blocksRun(failures map[int]string, ran *[]int) func(numBlocks int) (Seed, error) {
+	return func(numBlocks int) (Seed, error) {
+		*ran = append(*ran, numBlocks)
+		category, from := "", -1
+		for n, c := range failures {
+			if n <= numBlocks && n > from {
+				category, from = c, n
+			}
+		}
+		if category == "" {
+			return Seed{}, nil
+		}
+		return Seed{Failed: true, Failure: &failureInfo{Category: category}}, errors.New("exit status 1")
+	}
+}
+
+func TestBisectBlocks(t *testing.T) {
+	var ran []int
+	fail, failing, err := bisectBlocks(context.Background(), 100, blocksRun(map[int]string{37: failurePanic}, &ran))
+	require.NoError(t, err)
+	require.Equal(t, 37, fail)
+	require.Equal(t, failurePanic, failing.Failure.Category)
+	require.Equal(t, 100, ran[0])
+
+	// failing with another category counts as passing
+	ran = nil
+	fail, _, err = bisectBlocks(context.Background(), 100,
+		blocksRun(map[int]string{10: failureTimeout, 60: failurePanic}, &ran))
+	require.NoError(t, err)
+	require.Equal(t, 60, fail)
+
+	ran = nil
+	fail, _, err = bisectBlocks(context.Background(), 1, blocksRun(map[int]string{1: failurePanic}, &ran))
+	require.NoError(t, err)
+	require.Equal(t, 1, fail)
+	require.Equal(t, []int{1}, ran)
+}
+
+func TestBisectBlocksNoFailure(t *testing.T) {
+	var ran []int
+	_, _, err := bisectBlocks(context.Background(), 100, blocksRun(nil, &ran))
+	require.Error(t, err)
+	require.Equal(t, []int{100}, ran)
+
+	// a simulation that could not be run stops the bisection
+	_, _, err = bisectBlocks(context.Background(), 100, func(int) (Seed, error) {
+		return Seed{}, errors.New("fork/exec: no such file")
+	})
+	require.EqualError(t, err, "fork/exec: no such file")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, _, err = bisectBlocks(ctx, 100, blocksRun(map[int]string{1: failurePanic}, &ran))
+	require.Equal(t, context.Canceled, err)
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+	"syscall"
+)
+
+// failure categories, in the order they are reported
+const (
+	failureBuild          = "build failure"
+	failureNonDeterminism = "non-determinism"
+	failureTimeout        = "test timeout"
+	failureInvariant      = "invariant broken"
+	failureAppHash        = "app hash mismatch"
+	failurePanic          = "panic"
+	failureSignal         = "killed by signal"
+	failureUnknown        = "unknown"
+	maxStackFrames        = 5
+	maxFailureLineSize    = 1024 * 1024
+	// lines kept after a failure pattern matched, to find the stack trace in
+	failureContextLines = 64
+)
+
+var failureCategories = []string{
+	failureBuild, failureNonDeterminism, failureTimeout, failureInvariant, failureAppHash, failurePanic, failureSignal, failureUnknown,
+}
+
+var (
+	reBuildFailed = regexp.MustCompile(`\[(?:build|setup) failed\]|^# \S+$|cannot find package|^can't load package`)
+	reTimeout     = regexp.MustCompile(`^panic: test timed out after (\S+)`)
+	reInvariant   = regexp.MustCompile(`(?i)invariants? broken:?\s*(.*)`)
+	reAppHash     = regexp.MustCompile(`(?i)app ?hash mismatch|wrong Block\.Header\.AppHash`)
+	rePanic       = regexp.MustCompile(`^panic: (.*)`)
+	reSignal      = regexp.MustCompile(`^signal: (\S+)`)
+	reGoroutine   = regexp.MustCompile(`^goroutine \d+ \[.*\]:$`)
+)
+
+type failureInfo struct {
+	Category string   `json:"category"`
+	Detail   string   `json:"detail,omitempty"`
+	Stack    []string `json:"stack,omitempty"`
+	// import/export round trip stage that failed
+	Stage string `json:"stage,omitempty"`
+}
+
+// classifyFailure works out why a simulation failed from the error returned by
+// exec.Cmd and the seed's output files.
+func classifyFailure(seed Seed, waitErr error) *failureInfo {
+	c := newOutputClassifier()
+	for _, fileName := range []string{seed.Stderr, seed.Stdout} {
+		// the lines read before an error are still classified
+		if err := scanLines(fileName, c.add); err != nil {
+			log.Printf("WARNING: classifyFailure: %v", err)
+		}
+	}
+
+	info := c.result()
+	if info.Category == failureUnknown {
+		var exitErr *exec.ExitError
+		if errors.As(waitErr, &exitErr) {
+			if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+				info.Category = failureSignal
+				info.Detail = status.Signal().String()
+			}
+		}
+	}
+	return info
+}
+
+// classifyOutput matches the simulation output against the known failure patterns.
+func classifyOutput(lines []string) *failureInfo {
+	c := newOutputClassifier()
+	for _, line := range lines {
+		c.add(line)
+	}
+	return c.result()
+}
+
+// outputClassifier matches the simulation output, fed to it line by line,
+// against the known failure patterns. Categories are checked from the most to
+// the least specific, since e.g. a broken invariant also shows up as a panic.
+// Only the lines following the first match of each pattern are kept, the
+// output of a long simulation can be huge.
+type outputClassifier struct {
+	matchers []*failureMatcher
+}
+
+type failureMatcher struct {
+	category string
+	re       *regexp.Regexp
+	detail   string
+	// the matching line and the ones following it, up to failureContextLines
+	rest []string
+}
+
+func newOutputClassifier() *outputClassifier {
+	c := &outputClassifier{}
+	for _, m := range []struct {
+		category string
+		re       *regexp.Regexp
+	}{
+		{failureBuild, reBuildFailed},
+		{failureTimeout, reTimeout},
+		{failureInvariant, reInvariant},
+		{failureAppHash, reAppHash},
+		{failurePanic, rePanic},
+		// go test reports a test binary killed by a signal, e.g. by the OOM killer, and exits with status 1
+		{failureSignal, reSignal},
+	} {
+		c.matchers = append(c.matchers, &failureMatcher{category: m.category, re: m.re})
+	}
+	return c
+}
+
+func (c *outputClassifier) add(line string) {
+	for _, m := range c.matchers {
+		if m.rest != nil {
+			if len(m.rest) < failureContextLines {
+				m.rest = append(m.rest, line)
+			}
+			continue
+		}
+		if match := m.re.FindStringSubmatch(line); match != nil {
+			m.detail = strings.TrimSpace(line)
+			if len(match) > 1 && match[len(match)-1] != "" {
+				m.detail = strings.TrimSpace(match[len(match)-1])
+			}
+			m.rest = []string{line}
+		}
+	}
+}
+
+func (c *outputClassifier) result() *failureInfo {
+	for _, m := range c.matchers {
+		if m.rest == nil {
+			continue
+		}
+		info := &failureInfo{Category: m.category, Detail: m.detail}
+		if m.category != failureBuild {
+			info.Stack = extractStack(m.rest, maxStackFrames)
+		} else if strings.HasPrefix(m.detail, "# ") && len(m.rest) > 1 && strings.TrimSpace(m.rest[1]) != "" {
+			// the compiler errors follow the header of the package that failed to build
+			info.Detail = strings.TrimSpace(m.rest[1])
+		}
+		return info
+	}
+	return &failureInfo{Category: failureUnknown}
+}
+
+// extractStack returns the first frames of the first goroutine trace found in
+// lines, skipping the runtime and testing frames that every panic carries.
+func extractStack(lines []string, maxFrames int) (frames []string) {
+	start := -1
+	for i, line := range lines {
+		if reGoroutine.MatchString(line) {
+			start = i + 1
+			break
+		}
+	}
+	if start < 0 {
+		return nil
+	}
+
+	// frames are printed as pairs of lines: the function call, then the indented file:line
+	for i := start; i+1 < len(lines) && len(frames) < maxFrames; i += 2 {
+		fn, loc := lines[i], strings.TrimSpace(lines[i+1])
+		if fn == "" || strings.HasPrefix(fn, "created by ") || !strings.HasPrefix(lines[i+1], "\t") {
+			break
+		}
+		if strings.HasPrefix(fn, "runtime.") || strings.HasPrefix(fn, "panic(") || strings.HasPrefix(fn, "testing.") {
+			continue
+		}
+		if idx := strings.LastIndex(loc, " +0x"); idx > 0 {
+			loc = loc[:idx]
+		}
+		frames = append(frames, fmt.Sprintf("%s %s", fn, loc))
+	}
+	return
+}
+
+// scanLines calls fn with every line of a file. Lines end with \n or \r, the
+// simulations log their progress with \r only, and lines longer than
+// maxFailureLineSize are split.
+func scanLines(fileName string, fn func(line string)) error {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	sc := bufio.NewScanner(file)
+	sc.Buffer(make([]byte, 64*1024), maxFailureLineSize)
+	sc.Split(splitLines)
+	for sc.Scan() {
+		fn(sc.Text())
+	}
+	return sc.Err()
+}
+
+func splitLines(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF || len(data) >= maxFailureLineSize {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// buildFailureSummary groups the failed seeds by failure category and lists the flaky ones.
+func buildFailureSummary(results []Seed) string {
+	groups := make(map[string][]Seed)
+	var flaky []Seed
+	for _, seed := range results {
+		if seed.Flaky {
+			flaky = append(flaky, seed)
+		}
+		if !seed.Failed {
+			continue
+		}
+		category := failureUnknown
+		if seed.Failure != nil {
+			category = seed.Failure.Category
+		}
+		groups[category] = append(groups[category], seed)
+	}
+	if len(groups) == 0 && len(flaky) == 0 {
+		return ""
+	}
+
+	var summary strings.Builder
+	if len(groups) > 0 {
+		summary.WriteString("Failures by category:\n")
+	}
+	for _, category := range failureCategories {
+		group, ok := groups[category]
+		if !ok {
+			continue
+		}
+		summary.WriteString(fmt.Sprintf("%s (%d): %s\n", category, len(group), joinSeedNums(group)))
+
+		// the details of the first seed are usually enough to tell what went wrong
+		if first := group[0].Failure; first != nil {
+			if stages := joinFailedStages(group); stages != "" {
+				summary.WriteString(fmt.Sprintf("    at stage %s\n", stages))
+			}
+			if first.Detail != "" {
+				summary.WriteString(fmt.Sprintf("    %s\n", first.Detail))
+			}
+			for _, frame := range first.Stack {
+				summary.WriteString(fmt.Sprintf("        %s\n", frame))
+			}
+		}
+	}
+	if len(flaky) > 0 {
+		summary.WriteString(fmt.Sprintf("Flaky, passed on retry (%d): %s\n", len(flaky), joinSeedNums(flaky)))
+	}
+	return summary.String()
+}
+
+// joinFailedStages lists the round trip stages the seeds of a group failed at, with their seeds.
+func joinFailedStages(group []Seed) string {
+	bySeed := make(map[string][]Seed)
+	var stages []string
+	for _, seed := range group {
+		if seed.Failure == nil || seed.Failure.Stage == "" {
+			continue
+		}
+		if _, ok := bySeed[seed.Failure.Stage]; !ok {
+			stages = append(stages, seed.Failure.Stage)
+		}
+		bySeed[seed.Failure.Stage] = append(bySeed[seed.Failure.Stage], seed)
+	}
+	if len(stages) == 1 {
+		return stages[0]
+	}
+	for i, stage := range stages {
+		stages[i] = fmt.Sprintf("%s (%s)", stage, joinSeedNums(bySeed[stage]))
+	}
+	return strings.Join(stages, ", ")
+}
+
+func joinSeedNums(group []Seed) string {
+	sort.Slice(group, func(i, j int) bool {
+		if group[i].Num != group[j].Num {
+			return group[i].Num < group[j].Num
+		}
+		return group[i].Cell < group[j].Cell
+	})
+	nums := make([]string, len(group))
+	for i, seed := range group {
+		nums[i] = seed.name()
+	}
+	return strings.Join(nums, ", ")
+}
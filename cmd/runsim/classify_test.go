@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const panicOutput = `=== RUN   TestFullAppSimulation
+panic: runtime error: invalid memory address or nil pointer dereference [recovered]
+	panic: runtime error: invalid memory address or nil pointer dereference
+[signal SIGSEGV: segmentation violation code=0x1 addr=0x0 pc=0x12a3f4e]
+
+goroutine 7 [running]:
+testing.tRunner.func1(0xc000510100)
+	/usr/local/go/src/testing/testing.go:874 +0x3a3
+panic(0x1a3c7e0, 0x2c7bd20)
+	/usr/local/go/src/runtime/panic.go:679 +0x1b2
+github.com/cosmos/cosmos-sdk/x/staking/keeper.Keeper.Delegate(0x0, 0x0)
+	/go/src/github.com/cosmos/cosmos-sdk/x/staking/keeper/delegation.go:534 +0x5e
+github.com/cosmos/cosmos-sdk/x/staking/simulation.SimulateMsgDelegate.func1(0x1)
+	/go/src/github.com/cosmos/cosmos-sdk/x/staking/simulation/operations.go:219 +0x6b4
+testing.tRunner(0xc000510100, 0x1d7a0e8)
+	/usr/local/go/src/testing/testing.go:909 +0xc9
+`
+
+func TestClassifyOutput(t *testing.T) {
+	tests := []struct {
+		name     string
+		output   string
+		category string
+		detail   string
+		stack    []string
+	}{
+		{
+			name:     "panic",
+			output:   panicOutput,
+			category: failurePanic,
+			detail:   "runtime error: invalid memory address or nil pointer dereference [recovered]",
+			stack: []string{
+				"github.com/cosmos/cosmos-sdk/x/staking/keeper.Keeper.Delegate(0x0, 0x0) " +
+					"/go/src/github.com/cosmos/cosmos-sdk/x/staking/keeper/delegation.go:534",
+				"github.com/cosmos/cosmos-sdk/x/staking/simulation.SimulateMsgDelegate.func1(0x1) " +
+					"/go/src/github.com/cosmos/cosmos-sdk/x/staking/simulation/operations.go:219",
+			},
+		},
+		{
+			name:     "invariant",
+			output:   "Simulating... block 120/500\npanic: invariant broken: bank: total supply invariant\n",
+			category: failureInvariant,
+			detail:   "bank: total supply invariant",
+		},
+		{
+			name:     "app hash",
+			output:   "ERROR: app hash mismatch at height 12\n",
+			category: failureAppHash,
+			detail:   "ERROR: app hash mismatch at height 12",
+		},
+		{
+			name:     "timeout",
+			output:   "panic: test timed out after 2h0m0s\n\ngoroutine 1 [chan receive]:\n",
+			category: failureTimeout,
+			detail:   "2h0m0s",
+		},
+		{
+			name: "build",
+			output: "# github.com/cosmos/cosmos-sdk/simapp\nsimapp/app.go:12:2: undefined: foo\n" +
+				"FAIL\tgithub.com/cosmos/cosmos-sdk/simapp [build failed]\n",
+			category: failureBuild,
+			detail:   "simapp/app.go:12:2: undefined: foo",
+		},
+		{
+			name:     "build without compiler errors",
+			output:   "FAIL\tgithub.com/cosmos/cosmos-sdk/simapp [setup failed]\n",
+			category: failureBuild,
+			detail:   "FAIL\tgithub.com/cosmos/cosmos-sdk/simapp [setup failed]",
+		},
+		{
+			name:     "test binary killed",
+			output:   "Simulating... block 120/500\nsignal: killed\nFAIL\tgithub.com/cosmos/cosmos-sdk/simapp\t812.345s\n",
+			category: failureSignal,
+			detail:   "killed",
+		},
+		{
+			name:     "unknown",
+			output:   "--- FAIL: TestFullAppSimulation (12.00s)\nFAIL\n",
+			category: failureUnknown,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := classifyOutput(strings.Split(tt.output, "\n"))
+			require.Equal(t, tt.category, info.Category)
+			require.Equal(t, tt.detail, info.Detail)
+			require.Equal(t, tt.stack, info.Stack)
+		})
+	}
+}
+
+func TestBuildFailureSummary(t *testing.T) {
+	summary := buildFailureSummary([]Seed{
+		{Num: 32, Failed: true, Failure: &failureInfo{Category: failurePanic, Detail: "boom"}},
+		{Num: 7, Failed: true, Failure: &failureInfo{Category: failurePanic, Detail: "bang"}},
+		{Num: 1, Failed: true, Failure: &failureInfo{Category: failureInvariant, Detail: "bank: total supply"}},
+		{Num: 2},
+		{Num: 4, Flaky: true},
+	})
+	require.Equal(t, "Failures by category:\n"+
+		"invariant broken (1): 1\n"+
+		"    bank: total supply\n"+
+		"panic (2): 7, 32\n"+
+		"    bang\n"+
+		"Flaky, passed on retry (1): 4\n", summary)
+
+	require.Equal(t, "Flaky, passed on retry (1): 4\n", buildFailureSummary([]Seed{{Num: 4, Flaky: true}}))
+	require.Empty(t, buildFailureSummary([]Seed{{Num: 2}}))
+}
+
+func TestClassifyFailureLongOutput(t *testing.T) {
+	dir, err := ioutil.TempDir("", "runsim-classify")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// the simulations overwrite their progress with \r, leaving a single huge line
+	var stdout strings.Builder
+	for h := 1; stdout.Len() <= 2*maxFailureLineSize; h++ {
+		stdout.WriteString(fmt.Sprintf("\rSimulating... block %d/100000, operation 0/10.", h))
+	}
+	stdout.WriteString("\n" + panicOutput)
+	seed := Seed{Stdout: filepath.Join(dir, "stdout"), Stderr: filepath.Join(dir, "stderr")}
+	require.NoError(t, ioutil.WriteFile(seed.Stdout, []byte(stdout.String()), 0644))
+	require.NoError(t, ioutil.WriteFile(seed.Stderr, nil, 0644))
+
+	info := classifyFailure(seed, nil)
+	require.Equal(t, failurePanic, info.Category)
+	require.Len(t, info.Stack, 2)
+
+	// a long line without any line break is split rather than failing the scan
+	require.NoError(t, ioutil.WriteFile(seed.Stdout, []byte(strings.Repeat("x", 3*maxFailureLineSize)+"\npanic: boom\n"), 0644))
+	var lines []string
+	require.NoError(t, scanLines(seed.Stdout, func(line string) { lines = append(lines, line) }))
+	require.Len(t, lines, 5)
+	require.Len(t, lines[0], maxFailureLineSize)
+	require.Equal(t, "panic: boom", lines[4])
+	require.Equal(t, "boom", classifyFailure(seed, nil).Detail)
+}
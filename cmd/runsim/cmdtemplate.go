@@ -0,0 +1,244 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+)
+
+// The simulation command is an argv template: the template string is split
+// into words first, then every word is expanded on its own, so parameter values
+// containing spaces or quotes always end up in a single argument. Words made
+// of template actions only are dropped when they expand to nothing, so that
+// arguments can be made conditional.
+const defaultCmdTemplate = `go test {{.Pkg}} -run {{.TestName}} -Enabled=true -NumBlocks={{.Blocks}} ` +
+	genesisArg + ` -Verbose=true -Commit=true -Seed={{.Seed}} -Period={{.Period}} ` +
+	`-ExportParamsPath {{.ExportParamsPath}} -ExportStatePath {{.ExportStatePath}} -v -timeout {{.Timeout}}`
+
+// the import stages of a round trip simulate from the state exported by the
+// previous stage. The simulation takes no -Params along with a -Genesis file.
+const genesisArg = `-Genesis={{if .ImportStatePath}}{{.ImportStatePath}}{{else}}{{.Genesis}}{{end}}`
+
+// parsed argv template of the simulation command
+var cmdTemplate []*template.Template
+
+// placeholders available to command templates
+type cmdParams struct {
+	Pkg              string
+	TestName         string
+	Blocks           string
+	Period           string
+	Genesis          string
+	Seed             int
+	ExportStatePath  string
+	ExportParamsPath string
+	// genesis document of the state exported by the previous round trip stage
+	ImportStatePath  string
+	ImportParamsPath string
+	Timeout          time.Duration
+	TestBinary       string
+}
+
+// initCmdTemplate parses the simulation command template, read from fileName
+// if set, and checks that it expands. Without a template the default go test
+// command, or the precompiled test binary command with -Precompile, is used. A
+// template given with -Precompile has to run the {{.TestBinary}}.
+func initCmdTemplate(text, fileName string) (err error) {
+	if text == "" {
+		text = defaultCmdTemplate
+		if precompile {
+			text = precompiledCmdTemplate
+		}
+	}
+	if fileName != "" {
+		if text, err = readCmdTemplateFile(fileName); err != nil {
+			return
+		}
+	}
+	if precompile && !strings.Contains(text, ".TestBinary") {
+		return fmt.Errorf("-Precompile builds a test binary the command template does not run, use {{.TestBinary}}")
+	}
+	if cmdTemplate, err = parseCmdTemplate(text); err != nil {
+		return
+	}
+	_, err = expandCmdTemplate(cmdTemplate, cmdParams{})
+	return
+}
+
+// readCmdTemplateFile reads a command template from a file. Lines starting
+// with # are comments, newlines are treated as any other whitespace.
+func readCmdTemplateFile(fileName string) (string, error) {
+	content, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return "", err
+	}
+	var lines []string
+	for _, line := range strings.Split(string(content), "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), "#") {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func parseCmdTemplate(text string) ([]*template.Template, error) {
+	words, err := splitArgs(text)
+	if err != nil {
+		return nil, err
+	}
+	if len(words) == 0 {
+		return nil, fmt.Errorf("command template is empty")
+	}
+
+	argv := make([]*template.Template, len(words))
+	for i, word := range words {
+		if argv[i], err = template.New(fmt.Sprintf("arg%d", i)).Parse(word); err != nil {
+			return nil, err
+		}
+	}
+	return argv, nil
+}
+
+func expandCmdTemplate(argv []*template.Template, params cmdParams) ([]string, error) {
+	args := make([]string, 0, len(argv))
+	for _, tmpl := range argv {
+		var arg strings.Builder
+		if err := tmpl.Execute(&arg, params); err != nil {
+			return nil, err
+		}
+		if arg.Len() == 0 && isActionOnly(tmpl) {
+			continue
+		}
+		args = append(args, arg.String())
+	}
+	return args, nil
+}
+
+// isActionOnly reports whether a word of a command template has no literal text.
+func isActionOnly(tmpl *template.Template) bool {
+	if tmpl.Tree == nil {
+		return false
+	}
+	for _, node := range tmpl.Tree.Root.Nodes {
+		if node.Type() == parse.NodeText {
+			return false
+		}
+	}
+	return len(tmpl.Tree.Root.Nodes) > 0
+}
+
+func buildCmdArgs(testName, blocks, period, genesis, exportStatePath, exportParamsPath string, seed int) []string {
+	return expandCmd(cmdParams{
+		TestName:         testName,
+		Blocks:           blocks,
+		Period:           period,
+		Genesis:          genesis,
+		Seed:             seed,
+		ExportStatePath:  exportStatePath,
+		ExportParamsPath: exportParamsPath,
+	})
+}
+
+// expandCmd expands the simulation command template with the run-wide
+// package, timeout and test binary added to params.
+func expandCmd(params cmdParams) []string {
+	params.Pkg, params.Timeout, params.TestBinary = pkgName, timeout, testBinary
+	args, err := expandCmdTemplate(cmdTemplate, params)
+	if err != nil {
+		// the template was checked by initCmdTemplate already
+		panic(err)
+	}
+	return args
+}
+
+func execCmd(args []string) *exec.Cmd {
+	return exec.Command(args[0], args[1:]...)
+}
+
+// splitArgs splits a command line into words the way a POSIX shell would,
+// honouring single quotes, double quotes and backslash escapes. Whitespace
+// within template actions such as {{ .Seed }} does not split words.
+func splitArgs(text string) (args []string, err error) {
+	var (
+		word            strings.Builder
+		inWord          bool
+		inSingle, inDbl bool
+		inAction        bool
+		escaped         bool
+	)
+	runes := []rune(text)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case inAction:
+			word.WriteRune(r)
+			if r == '}' && i+1 < len(runes) && runes[i+1] == '}' {
+				word.WriteRune('}')
+				i++
+				inAction = false
+			}
+		case r == '{' && i+1 < len(runes) && runes[i+1] == '{':
+			word.WriteString("{{")
+			i++
+			inAction, inWord = true, true
+		case escaped:
+			word.WriteRune(r)
+			escaped = false
+		case inSingle:
+			if r == '\'' {
+				inSingle = false
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\\':
+			escaped, inWord = true, true
+		case inDbl:
+			if r == '"' {
+				inDbl = false
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\'':
+			inSingle, inWord = true, true
+		case r == '"':
+			inDbl, inWord = true, true
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+			if inWord {
+				args = append(args, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if inSingle || inDbl || inAction || escaped {
+		return nil, fmt.Errorf("unterminated quote, escape or action in %q", text)
+	}
+	if inWord {
+		args = append(args, word.String())
+	}
+	return
+}
+
+// quoteArgs joins args into a command line that can be pasted into a shell.
+func quoteArgs(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if arg != "" && strings.IndexFunc(arg, needsQuoting) < 0 {
+			quoted[i] = arg
+			continue
+		}
+		quoted[i] = "'" + strings.Replace(arg, "'", `'\''`, -1) + "'"
+	}
+	return strings.Join(quoted, " ")
+}
+
+func needsQuoting(r rune) bool {
+	return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./:=,+@%", r))
+}
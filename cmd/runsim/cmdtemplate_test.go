@@ -0,0 +1,103 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitArgs(t *testing.T) {
+	tests := []struct {
+		text string
+		args []string
+	}{
+		{"go test ./simapp", []string{"go", "test", "./simapp"}},
+		{"  go\ttest\n./simapp  ", []string{"go", "test", "./simapp"}},
+		{`-Genesis "/tmp/my genesis.json"`, []string{"-Genesis", "/tmp/my genesis.json"}},
+		{`-Genesis='/tmp/it''s'`, []string{"-Genesis=/tmp/its"}},
+		{`a\ b "c\"d" ''`, []string{"a b", `c"d`, ""}},
+		{"-Seed={{ .Seed }} {{.Blocks}}", []string{"-Seed={{ .Seed }}", "{{.Blocks}}"}},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		args, err := splitArgs(tt.text)
+		require.NoError(t, err, tt.text)
+		require.Equal(t, tt.args, args, tt.text)
+	}
+
+	for _, text := range []string{`"unterminated`, `'unterminated`, `trailing\`, `{{.Seed`} {
+		_, err := splitArgs(text)
+		require.Error(t, err, text)
+	}
+}
+
+func TestQuoteArgs(t *testing.T) {
+	args := []string{"go", "test", "-Genesis=", "/tmp/my genesis.json", "it's", ""}
+	quoted := quoteArgs(args)
+	require.Equal(t, `go test -Genesis= '/tmp/my genesis.json' 'it'\''s' ''`, quoted)
+
+	split, err := splitArgs(quoted)
+	require.NoError(t, err)
+	require.Equal(t, args, split)
+}
+
+func TestCmdTemplate(t *testing.T) {
+	argv, err := parseCmdTemplate(`./simapp.test -test.run {{.TestName}} -Seed={{ .Seed }} -Genesis "{{.Genesis}}" -DBBackend goleveldb`)
+	require.NoError(t, err)
+
+	args, err := expandCmdTemplate(argv, cmdParams{TestName: "TestFullAppSimulation", Seed: 42, Genesis: "/tmp/a b.json"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"./simapp.test", "-test.run", "TestFullAppSimulation", "-Seed=42",
+		"-Genesis", "/tmp/a b.json", "-DBBackend", "goleveldb"}, args)
+
+	argv, err = parseCmdTemplate(defaultCmdTemplate)
+	require.NoError(t, err)
+	args, err = expandCmdTemplate(argv, cmdParams{
+		Pkg: "./simapp", TestName: "TestFullAppSimulation", Blocks: "100", Period: "5", Seed: 7,
+		ExportStatePath: "/tmp/state.json", ExportParamsPath: "/tmp/params.json", Timeout: time.Hour,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "go test ./simapp -run TestFullAppSimulation -Enabled=true -NumBlocks=100 -Genesis= "+
+		"-Verbose=true -Commit=true -Seed=7 -Period=5 -ExportParamsPath /tmp/params.json "+
+		"-ExportStatePath /tmp/state.json -v -timeout 1h0m0s", quoteArgs(args))
+
+	args, err = expandCmdTemplate(argv, cmdParams{Pkg: "./simapp", TestName: "TestAppImportExport", Genesis: "/tmp/genesis.json",
+		ImportStatePath: "/tmp/state-genesis.json", ImportParamsPath: "/tmp/params.json"})
+	require.NoError(t, err)
+	require.Contains(t, args, "-Genesis=/tmp/state-genesis.json")
+	require.NotContains(t, args, "-Params=/tmp/params.json")
+
+	// only words made of actions are dropped when empty
+	argv, err = parseCmdTemplate(`go test {{.Genesis}} '' -Genesis={{.Genesis}}`)
+	require.NoError(t, err)
+	args, err = expandCmdTemplate(argv, cmdParams{})
+	require.NoError(t, err)
+	require.Equal(t, []string{"go", "test", "", "-Genesis="}, args)
+
+	argv, err = parseCmdTemplate("go test {{.Unknown}}")
+	require.NoError(t, err)
+	_, err = expandCmdTemplate(argv, cmdParams{})
+	require.Error(t, err)
+}
+
+func TestInitCmdTemplatePrecompile(t *testing.T) {
+	defer func(saved []*template.Template) { cmdTemplate, precompile = saved, false }(cmdTemplate)
+	precompile = true
+
+	require.NoError(t, initCmdTemplate("", ""))
+	require.NoError(t, initCmdTemplate("taskset -c 0-3 {{.TestBinary}} -test.run {{.TestName}}", ""))
+	// the test binary built would not be run
+	require.Error(t, initCmdTemplate("go test {{.Pkg}} -run {{.TestName}}", ""))
+
+	dir, err := ioutil.TempDir("", "runsim-cmdtemplate")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	fileName := filepath.Join(dir, "cmd")
+	require.NoError(t, ioutil.WriteFile(fileName, []byte("# go test\ngo test {{.Pkg}}\n"), 0644))
+	require.Error(t, initCmdTemplate("", fileName))
+}
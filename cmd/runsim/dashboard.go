@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	dashboardRefresh  = time.Second
+	dashboardLogLines = 5
+	dashboardTailSize = 4096
+	dashboardLineSize = 100
+)
+
+// progress lines printed by the simulation in verbose mode, e.g. "Simulating... block 12/500, operation 3/100."
+var reBlockHeight = regexp.MustCompile(`block (\d+)/(\d+)`)
+
+// the dashboard is nil unless enabled with -Dashboard
+var dash *dashboard
+
+// dashboard redraws the state of the worker pool on the terminal. While it's
+// running the runsim log only goes to the log file, the latest lines are shown
+// at the bottom of the dashboard instead.
+type dashboard struct {
+	mtx       sync.Mutex
+	out       io.Writer
+	recentLog []string
+
+	stop, stopped chan struct{}
+}
+
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func newDashboard(out io.Writer) *dashboard {
+	return &dashboard{
+		out:     out,
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+}
+
+// Write keeps the latest runsim log lines, it's meant to be hooked to the logger.
+func (d *dashboard) Write(p []byte) (int, error) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		d.recentLog = append(d.recentLog, line)
+	}
+	if len(d.recentLog) > dashboardLogLines {
+		d.recentLog = d.recentLog[len(d.recentLog)-dashboardLogLines:]
+	}
+	return len(p), nil
+}
+
+func (d *dashboard) run() {
+	defer close(d.stopped)
+	ticker := time.NewTicker(dashboardRefresh)
+	defer ticker.Stop()
+	for {
+		d.render()
+		select {
+		case <-ticker.C:
+		case <-d.stop:
+			d.render()
+			return
+		}
+	}
+}
+
+func (d *dashboard) Stop() {
+	close(d.stop)
+	<-d.stopped
+}
+
+func (d *dashboard) render() {
+	snap := progress.snapshot()
+
+	var screen strings.Builder
+	// move the cursor home and clear the screen
+	screen.WriteString("\033[H\033[2J")
+
+	screen.WriteString(fmt.Sprintf("runsim %s %s blocks, period %s — elapsed %s\n",
+		testname, blocks, period, time.Since(snap.Started).Round(time.Second)))
+	screen.WriteString(fmt.Sprintf("seeds: %d/%d done  passed: %d  failed: %d  flaky: %d  interrupted: %d  ETA: %s\n\n",
+		snap.done(), snap.Total, snap.Passed, snap.Failed, snap.Flaky, snap.Interrupted, snap.eta()))
+
+	for id, w := range snap.Workers {
+		if !w.Busy {
+			screen.WriteString(fmt.Sprintf("W%-3d idle\n", id))
+			continue
+		}
+		height, lastLine := tailProgress(w.Seed.Stdout)
+		screen.WriteString(fmt.Sprintf("W%-3d seed %-10s pid %-7d %9s  %-12s %s\n", id, w.Seed.name(), w.Pid,
+			time.Since(w.Started).Round(time.Second), height, truncate(lastLine, dashboardLineSize)))
+	}
+
+	screen.WriteString("\n")
+	d.mtx.Lock()
+	for _, line := range d.recentLog {
+		screen.WriteString(truncate(line, 2*dashboardLineSize) + "\n")
+	}
+	d.mtx.Unlock()
+	_, _ = io.WriteString(d.out, screen.String())
+}
+
+// tailProgress returns the latest block height and the last line of a simulation's output.
+func tailProgress(fileName string) (height, lastLine string) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return
+	}
+	offset := info.Size() - dashboardTailSize
+	if offset < 0 {
+		offset = 0
+	}
+	buf := make([]byte, dashboardTailSize)
+	n, _ := file.ReadAt(buf, offset)
+	tail := string(buf[:n])
+
+	if matches := reBlockHeight.FindAllStringSubmatch(tail, -1); len(matches) > 0 {
+		last := matches[len(matches)-1]
+		height = fmt.Sprintf("block %s/%s", last[1], last[2])
+	}
+	// the simulation rewrites its progress line with carriage returns
+	lines := strings.FieldsFunc(tail, func(r rune) bool { return r == '\n' || r == '\r' })
+	for i := len(lines) - 1; i >= 0; i-- {
+		if line := strings.TrimSpace(lines[i]); line != "" {
+			lastLine = line
+			break
+		}
+	}
+	return
+}
+
+func truncate(s string, size int) string {
+	if len(s) <= size {
+		return s
+	}
+	return s[:size-3] + "..."
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTailProgress(t *testing.T) {
+	dir, err := ioutil.TempDir("", "runsim-dashboard")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// the SDK rewrites its progress line with carriage returns, the output
+	// is longer than the tail read
+	var out strings.Builder
+	out.WriteString("Starting SimulateFromSeed with randomness created with seed 7\n")
+	for h := 1; h <= 250; h++ {
+		out.WriteString(fmt.Sprintf("\rSimulating... block %d/500, operation 0/10.", h))
+		out.WriteString(fmt.Sprintf("\rSimulating... block %d/500, operation %d/10.", h, h%9+1))
+	}
+	require.True(t, out.Len() > dashboardTailSize)
+	fileName := filepath.Join(dir, "sim_log-7.stdout")
+	require.NoError(t, ioutil.WriteFile(fileName, []byte(out.String()), 0644))
+
+	height, lastLine := tailProgress(fileName)
+	require.Equal(t, "block 250/500", height)
+	require.Equal(t, "Simulating... block 250/500, operation 8/10.", lastLine)
+
+	// the height is kept once the simulation prints something else
+	out.WriteString("\n--- PASS: TestFullAppSimulation (812.34s)\nPASS\n\n")
+	require.NoError(t, ioutil.WriteFile(fileName, []byte(out.String()), 0644))
+	height, lastLine = tailProgress(fileName)
+	require.Equal(t, "block 250/500", height)
+	require.Equal(t, "PASS", lastLine)
+
+	require.NoError(t, ioutil.WriteFile(fileName, []byte("ok\n"), 0644))
+	height, lastLine = tailProgress(fileName)
+	require.Equal(t, "", height)
+	require.Equal(t, "ok", lastLine)
+
+	height, lastLine = tailProgress(filepath.Join(dir, "missing"))
+	require.Equal(t, "", height)
+	require.Equal(t, "", lastLine)
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+)
+
+// app hashes printed by the simulation or tendermint, e.g. "appHash=4F8A..." or "app_hash: 4f8a..."
+var reAppHashValue = regexp.MustCompile(`(?i)app[ _]?hash\W{1,3}([0-9a-f]{16,})`)
+
+// size of the values quoted in non-determinism reports
+const diffValueSize = 80
+
+// the determinism checker is nil unless enabled with -Determinism
+var determinism *determinismChecker
+
+// determinismChecker collects the runs of every seed and compares them once
+// they all finished. The runs are queued like separate seeds, so they can be
+// simulated on different workers at once.
+type determinismChecker struct {
+	mtx  sync.Mutex
+	runs int
+	done map[string][]determinismRun
+	// seeds whose result was already returned, i.e. interrupted ones
+	reported map[string]bool
+}
+
+type determinismRun struct {
+	seed Seed
+	err  error
+}
+
+func newDeterminismChecker(runs int) *determinismChecker {
+	return &determinismChecker{runs: runs, done: make(map[string][]determinismRun), reported: make(map[string]bool)}
+}
+
+// seedRuns returns a copy of the seed for each run, with its own logs and exports.
+func (c *determinismChecker) seedRuns(seed Seed) []Seed {
+	runs := make([]Seed, c.runs)
+	for i := range runs {
+		run := seed
+		run.Run = i + 1
+		run.Stdout = buildRunFileName(seed.Stdout, run.Run)
+		run.Stderr = buildRunFileName(seed.Stderr, run.Run)
+		run.ExportParams = buildRunFileName(seed.ExportParams, run.Run)
+		run.ExportState = buildRunFileName(seed.ExportState, run.Run)
+		runs[i] = run
+	}
+	return runs
+}
+
+func buildRunFileName(fileName string, run int) string {
+	ext := filepath.Ext(fileName)
+	return fmt.Sprintf("%s-run-%d%s", fileName[:len(fileName)-len(ext)], run, ext)
+}
+
+// add records a finished run. Once all the runs of the seed are done it returns
+// the seed's result and true. An interrupted run is returned right away.
+func (c *determinismChecker) add(run Seed, err error) (Seed, error, bool) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if c.reported[run.name()] {
+		return run, err, false
+	}
+	if run.Interrupted {
+		c.reported[run.name()] = true
+		return run, err, true
+	}
+
+	c.done[run.name()] = append(c.done[run.name()], determinismRun{run, err})
+	if len(c.done[run.name()]) < c.runs {
+		return run, err, false
+	}
+	runs := c.done[run.name()]
+	delete(c.done, run.name())
+	c.reported[run.name()] = true
+
+	sort.Slice(runs, func(i, j int) bool { return runs[i].seed.Run < runs[j].seed.Run })
+	seed, err := compareRuns(runs)
+	return seed, err, true
+}
+
+// compareRuns merges the runs of a seed into the seed's result, which is
+// non-deterministic if the runs disagree on the outcome, the failure, the
+// exported state or the app hashes.
+func compareRuns(runs []determinismRun) (Seed, error) {
+	seed, err := runs[0].seed, runs[0].err
+	for _, run := range runs[1:] {
+		seed.Runs = append(seed.Runs, run.seed.Stderr, run.seed.Stdout, run.seed.ExportParams, run.seed.ExportState)
+		seed.Runs = append(seed.Runs, run.seed.PrevAttempts...)
+	}
+
+	var detail string
+	for _, run := range runs[1:] {
+		if detail = compareRun(runs[0], run); detail != "" {
+			break
+		}
+	}
+	if detail == "" {
+		return seed, err
+	}
+
+	seed.Failed = true
+	seed.Failure = &failureInfo{Category: failureNonDeterminism, Detail: detail}
+	return seed, errors.New(detail)
+}
+
+// compareRun returns how b differs from a, or "" if it doesn't.
+func compareRun(a, b determinismRun) string {
+	switch {
+	case a.err == nil && b.err != nil:
+		return fmt.Sprintf("run %d passed, run %d failed (%s)", a.seed.Run, b.seed.Run, b.seed.Failure.Category)
+	case a.err != nil && b.err == nil:
+		return fmt.Sprintf("run %d failed (%s), run %d passed", a.seed.Run, a.seed.Failure.Category, b.seed.Run)
+	case a.err != nil:
+		fa, fb := a.seed.Failure, b.seed.Failure
+		if fa.Category != fb.Category || fa.Detail != fb.Detail {
+			return fmt.Sprintf("run %d failed (%s: %s), run %d failed (%s: %s)",
+				a.seed.Run, fa.Category, fa.Detail, b.seed.Run, fb.Category, fb.Detail)
+		}
+		return ""
+	}
+
+	if fileExists(a.seed.ExportState) && fileExists(b.seed.ExportState) {
+		detail, err := compareStates(a.seed, b.seed)
+		if err != nil {
+			log.Printf("WARNING: seed %s: comparing exported states: %v", a.seed.name(), err)
+		}
+		return detail
+	}
+	detail, err := compareAppHashes(a.seed, b.seed)
+	if err != nil {
+		log.Printf("WARNING: seed %s: comparing app hashes: %v", a.seed.name(), err)
+	}
+	return detail
+}
+
+// compareStates reports the first key at which the exported states of two runs differ.
+func compareStates(a, b Seed) (detail string, err error) {
+	stateA, err := readJSON(a.ExportState)
+	if err != nil {
+		return
+	}
+	stateB, err := readJSON(b.ExportState)
+	if err != nil {
+		return
+	}
+	diffJSON(stateA, stateB, nil, func(d jsonDifference) bool {
+		detail = fmt.Sprintf("exported state differs at %s: run %d %s, run %d %s", formatPath(d.Path),
+			a.Run, describeValue(d.Kind != diffAdded, d.Old), b.Run, describeValue(d.Kind != diffRemoved, d.New))
+		return false
+	})
+	return
+}
+
+func describeValue(present bool, value interface{}) string {
+	if !present {
+		return "has no value"
+	}
+	return "= " + formatValue(value, diffValueSize)
+}
+
+// compareAppHashes compares the app hashes found in the output of two runs.
+func compareAppHashes(a, b Seed) (string, error) {
+	hashesA, err := readAppHashes(a.Stdout)
+	if err != nil {
+		return "", err
+	}
+	hashesB, err := readAppHashes(b.Stdout)
+	if err != nil {
+		return "", err
+	}
+	if len(hashesA) == 0 && len(hashesB) == 0 {
+		return "", fmt.Errorf("no exported state nor app hashes in the output")
+	}
+	for i := 0; i < len(hashesA) && i < len(hashesB); i++ {
+		if hashesA[i] != hashesB[i] {
+			return fmt.Sprintf("app hash #%d differs: run %d %s, run %d %s", i+1, a.Run, hashesA[i], b.Run, hashesB[i]), nil
+		}
+	}
+	if len(hashesA) != len(hashesB) {
+		return fmt.Sprintf("run %d logged %d app hashes, run %d logged %d", a.Run, len(hashesA), b.Run, len(hashesB)), nil
+	}
+	return "", nil
+}
+
+func readAppHashes(fileName string) ([]string, error) {
+	var hashes []string
+	err := scanLines(fileName, func(line string) {
+		if m := reAppHashValue.FindStringSubmatch(line); m != nil {
+			hashes = append(hashes, m[1])
+		}
+	})
+	return hashes, err
+}
+
+func fileExists(fileName string) bool {
+	_, err := os.Stat(fileName)
+	return err == nil
+}
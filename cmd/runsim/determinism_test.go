@@ -0,0 +1,43 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompareRuns(t *testing.T) {
+	dir, err := ioutil.TempDir("", "runsim-determinism")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	run := func(n int, state string) determinismRun {
+		seed := determinismRun{seed: Seed{Num: 3, Run: n}}
+		seed.seed.ExportState = filepath.Join(dir, "sim_state-3-run-"+strconv.Itoa(n)+".json")
+		require.NoError(t, ioutil.WriteFile(seed.seed.ExportState, []byte(state), 0644))
+		return seed
+	}
+
+	seed, err := compareRuns([]determinismRun{run(1, `{"bank":{"supply":"10"}}`), run(2, `{"bank":{"supply":"10"}}`)})
+	require.NoError(t, err)
+	require.False(t, seed.Failed)
+	require.Contains(t, seed.Runs, filepath.Join(dir, "sim_state-3-run-2.json"))
+
+	seed, err = compareRuns([]determinismRun{run(1, `{"bank":{"supply":"10"}}`), run(2, `{"bank":{"supply":"11"}}`)})
+	require.Error(t, err)
+	require.True(t, seed.Failed)
+	require.Equal(t, failureNonDeterminism, seed.Failure.Category)
+	require.Equal(t, `exported state differs at bank.supply: run 1 = "10", run 2 = "11"`, seed.Failure.Detail)
+
+	failed := run(2, `{}`)
+	failed.err = errors.New("exit status 1")
+	failed.seed.Failure = &failureInfo{Category: failurePanic}
+	seed, err = compareRuns([]determinismRun{run(1, `{}`), failed})
+	require.Error(t, err)
+	require.Equal(t, "run 1 passed, run 2 failed (panic)", seed.Failure.Detail)
+}
@@ -5,35 +5,114 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 )
 
 var (
 	genesis, blocks, period, simId, hostId, logObjPrefix string
+	journalPath, resumePath                              string
+	reportFormat, reportFile                             string
+	cmdTemplateText, cmdTemplateFile                     string
+	listenAddr                                           string
+	seedFile, replayPath                                 string
+	artifactStoreType, artifactDir                       string
+	logBucket, s3Endpoint                                string
+	archiveFormat                                        string
+	profilePath                                          string
+	historyPath                                          string
 
 	pkgName          = "./simapp"
 	seedOverrideList = ""
 
-	notifySlack, notifyGithub, exitOnFail bool
+	notifySlack, notifyGithub, exitOnFail, precompile, showDashboard, memSchedule bool
+
+	randomSeeds int
+	masterSeed  int64
+
+	presignLinks time.Duration
+
+	determinismRuns int
+
+	importExport bool
+	importTests  string
+
+	retries      int
+	retryBackoff time.Duration
+	gracePeriod  time.Duration
+
+	memLimit float64
+	seedMem  = "0"
 )
 
+// flagGiven reports whether a flag was set on the command line or by the run profile.
+func flagGiven(name string) (given bool) {
+	flag.Visit(func(f *flag.Flag) { given = given || f.Name == name })
+	return
+}
+
 func initFlags() {
-	flag.StringVar(&genesis, "Genesis", "", "genesis file path")
+	flag.StringVar(&profilePath, "Profile", "", "read the run's settings from a YAML or TOML profile, given as file or file:name for a named profile; flags and arguments given override it")
+	flag.StringVar(&genesis, "Genesis", "", "genesis file path, or comma-separated genesis files and directories of them to run every seed with each")
 	flag.StringVar(&pkgName, "SimAppPkg", "github.com/cosmos/cosmos-sdk/simapp", "sim app package")
 	flag.StringVar(&simId, "SimId", "", "long sim ID")
 	flag.StringVar(&hostId, "HostId", "", "long sim host ID")
-	flag.StringVar(&seedOverrideList, "Seeds", "", "override default seeds with comma-separated list")
-	flag.StringVar(&logObjPrefix, "LogObjPrefix", "", "the S3 object prefix used when uploading logs")
+	flag.StringVar(&seedOverrideList, "Seeds", "", "override default seeds with comma-separated list of seeds and ranges, e.g. 1,7,100-200")
+	flag.StringVar(&seedFile, "SeedFile", "", "read seeds and ranges from a file, one or more per line")
+	flag.IntVar(&randomSeeds, "RandomSeeds", 0, "run this many random seeds generated from -MasterSeed")
+	flag.Int64Var(&masterSeed, "MasterSeed", 0, "master seed of -RandomSeeds (default: current time, logged and recorded in the report)")
+	flag.StringVar(&replayPath, "ReplayFailures", "", "rerun the seeds that failed in a previous run, read from its JSON report or journal")
+	flag.StringVar(&logObjPrefix, "LogObjPrefix", "", "the object prefix used when uploading logs")
+	flag.StringVar(&artifactStoreType, "ArtifactStore", storeS3, "where logs and exports are published: s3 or local, which like -LogBucket publishes them without -Slack or -Github too")
+	flag.StringVar(&artifactDir, "ArtifactDir", "", "directory logs and exports are copied to with -ArtifactStore local")
+	flag.StringVar(&archiveFormat, "ArchiveFormat", archiveTarZst,
+		"format of the log and export archives, published or else kept in the logs temp dir if given: tar.zst, readable up to the last archived seed if runsim dies, or zip, only readable once the run ends")
+	flag.StringVar(&logBucket, "LogBucket", "",
+		"S3 bucket logs and exports are uploaded to (default: $RUNSIM_LOG_BUCKET, else the first bucket named "+logBucketPrefix+"*)")
+	flag.StringVar(&s3Endpoint, "S3Endpoint", "", "endpoint of an S3-compatible store (default: $RUNSIM_S3_ENDPOINT, else AWS)")
+	flag.DurationVar(&presignLinks, "PresignLinks", 0, "link to uploads with presigned URLs valid for this long instead of plain object URLs")
+	flag.StringVar(&cmdTemplateText, "CmdTemplate", "",
+		"simulation command template (default: go test invocation of -SimAppPkg), placeholders: {{.Pkg}} {{.TestName}} "+
+			"{{.Blocks}} {{.Period}} {{.Genesis}} {{.Seed}} {{.ExportStatePath}} {{.ExportParamsPath}} {{.ImportStatePath}} {{.ImportParamsPath}} {{.Timeout}} {{.TestBinary}}")
+	flag.BoolVar(&precompile, "Precompile", false, "build the simulation test binary once and run it directly for each seed, a -CmdTemplate has to run it as {{.TestBinary}}")
+	flag.StringVar(&cmdTemplateFile, "CmdTemplateFile", "", "read the simulation command template from a file, overrides -CmdTemplate")
 	flag.BoolVar(&notifySlack, "Slack", false, "report results to Slack channel")
 	flag.BoolVar(&notifyGithub, "Github", false, "update github check")
 	flag.BoolVar(&exitOnFail, "ExitOnFail", false, "exit on fail during multi-sim, print error")
+	flag.StringVar(&journalPath, "Journal", "", "record per-seed results to this file (default: in the logs temp dir)")
+	flag.StringVar(&resumePath, "Resume", "", "resume an interrupted run from its journal, skipping finished seeds")
+	flag.StringVar(&historyPath, "HistoryDB", "", "append the run's results to this history database, see the history subcommand")
+	flag.StringVar(&reportFormat, "Report", "", "write a results report in the given format: json or junit")
+	flag.StringVar(&reportFile, "ReportFile", "", "results report file path (default: in the logs temp dir)")
+	flag.BoolVar(&showDashboard, "Dashboard", false, "show a live status view of the workers when stdout is a terminal")
+	flag.StringVar(&listenAddr, "Listen", "", "serve the run status on /status and Prometheus metrics on /metrics at this address, e.g. :8080")
 	flag.IntVar(&jobs, "Jobs", jobs, "number of parallel processes")
-	flag.DurationVar(&timeout, "Timeout", defaultTimeout, "simulations fail if they run longer than the supplied timeout")
+	flag.IntVar(&determinismRuns, "Determinism", 0, "run each seed this many times, at least 2, and report seeds whose runs disagree")
+	flag.BoolVar(&importExport, "ImportExport", false, "run an import/export round trip for each seed: the test given, then the -ImportTests, each importing the previous one's exports "+
+		"(a -CmdTemplate has to pass the {{.ImportStatePath}} genesis on)")
+	flag.StringVar(&importTests, "ImportTests", defaultImportTests, "comma-separated tests run after the export stage by -ImportExport, optionally named as in stage=test")
+	flag.IntVar(&retries, "Retries", 0, "number of times a failed seed is retried before it's reported as failed")
+	flag.DurationVar(&retryBackoff, "RetryBackoff", 30*time.Second, "wait before the first retry of a failed seed, doubled on each further retry")
+	flag.BoolVar(&memSchedule, "MemSchedule", false, "hold seeds back while the host is short of memory")
+	flag.Float64Var(&memLimit, "MemLimit", 0.9, "fraction of the host memory simulations may use with -MemSchedule")
+	flag.StringVar(&seedMem, "SeedMem", seedMem, "estimated peak memory of a seed, e.g. 4GiB; the highest measured peak is used if larger")
+	flag.DurationVar(&gracePeriod, "GracePeriod", 30*time.Second, "on interrupt, time running simulations get to exit before they are killed")
+	flag.DurationVar(&timeout, "Timeout", defaultTimeout, "simulations are killed and fail if they run longer than the supplied timeout")
 
 	flag.Usage = func() {
 		_, _ = fmt.Fprintf(flag.CommandLine.Output(),
-			"Usage: %s [-Jobs maxprocs] [-ExitOnFail] [-Seeds comma-separated-seed-list] [-Genesis file-path] "+
-				"[-SimAppPkg file-path] [-Github] [-Slack] [-LogObjPrefix string] [blocks] [period] [testname]\n"+
-				"Run simulations in parallel\n", filepath.Base(os.Args[0]))
+			"Usage: %[1]s [-Profile file[:name]] [-Jobs maxprocs] [-ExitOnFail] [-Dashboard] [-Listen address] [-Seeds comma-separated-seed-list] [-SeedFile file-path] [-RandomSeeds n] [-MasterSeed int] [-ReplayFailures file-path] [-Genesis file-or-dir-list] "+
+				"[-SimAppPkg file-path] [-CmdTemplate string] [-CmdTemplateFile file-path] [-Precompile] [-MemSchedule] [-MemLimit fraction] [-SeedMem size] [-Determinism n] [-ImportExport] [-ImportTests list] [-Retries n] [-RetryBackoff duration] [-GracePeriod duration] [-Journal file-path] [-Resume file-path] [-Report json|junit] [-HistoryDB file-path] [-ReportFile file-path] [-Github] [-Slack] [-LogObjPrefix string] [-ArtifactStore s3|local] [-ArtifactDir dir-path] [-ArchiveFormat tar.zst|zip] [-LogBucket bucket] [-S3Endpoint url] [-PresignLinks duration] [blocks] [period] [testname]\n"+
+				"Run simulations in parallel, blocks may be a comma-separated list to run every seed with each block count;\n"+
+				"with -Profile the arguments may be left out\n\n"+
+				"Usage: %[1]s bisect [flags] [seed] [blocks] [period] [testname]\n"+
+				"Find the smallest block count at which a failing seed fails the same way\n\n"+
+				"Usage: %[1]s gitbisect [flags] [good-ref] [bad-ref] [seed] [blocks] [period] [testname]\n"+
+				"Find the first commit of the -SimAppPkg repository at which the seed fails the way it does at bad-ref\n\n"+
+				"Usage: %[1]s statediff [-Format text|jsonpatch] [-Modules list] [old-file] [new-file]\n"+
+				"Compare two exported states or params by module and key\n\n"+
+				"Usage: %[1]s history [-HistoryDB file-path] [-Test name] [-Commits n] [-Regression factor]\n"+
+				"Show the pass rates of the seeds recorded with -HistoryDB by commit, and their duration regressions\n",
+			filepath.Base(os.Args[0]))
 		flag.PrintDefaults()
 	}
 }
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// runGitBisect implements "runsim gitbisect [flags] good bad seed blocks period testname".
+// It checks out the commits between good and bad of the repository containing
+// -SimAppPkg in a scratch worktree, and finds the first commit at which the seed
+// fails the way it does at bad, after checking that it doesn't at good. Commits
+// that don't build are skipped.
+func runGitBisect(tempDir string, args []string) {
+	if err := flag.CommandLine.Parse(args); err != nil {
+		log.Fatalf("ERROR: %v", err)
+	}
+	if flag.NArg() != 6 {
+		log.Fatal("ERROR: wrong number of arguments, expected good bad seed blocks period testname")
+	}
+	good, bad := flag.Arg(0), flag.Arg(1)
+	seedNum, err := strconv.Atoi(flag.Arg(2))
+	if err != nil {
+		log.Fatalf("ERROR: invalid seed %q: %v", flag.Arg(2), err)
+	}
+	blocks, period, testname = flag.Arg(3), flag.Arg(4), flag.Arg(5)
+
+	if err := initCmdTemplate(cmdTemplateText, cmdTemplateFile); err != nil {
+		log.Fatalf("ERROR: initCmdTemplate: %v", err)
+	}
+	cells, single, err := buildMatrix(genesis, blocks)
+	if err == nil && cells != nil {
+		err = fmt.Errorf("gitbisect takes a single genesis file")
+	}
+	if err != nil {
+		log.Fatalf("ERROR: -Genesis: %v", err)
+	}
+	// the simulations run in the worktree, keep the genesis file where it was
+	if genesis = single; genesis != "" {
+		if genesis, err = filepath.Abs(genesis); err != nil {
+			log.Fatalf("ERROR: filepath.Abs: %v", err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		sig := <-sigs
+		log.Printf("Received %s, stopping the bisection...", sig)
+		cancel()
+	}()
+
+	progress = newProgressTracker(1, 0)
+	if err := gitBisect(ctx, tempDir, good, bad, seedNum); err != nil {
+		log.Fatalf("ERROR: %v", err)
+	}
+}
+
+func gitBisect(ctx context.Context, tempDir, good, bad string, seedNum int) (err error) {
+	out, err := exec.Command("go", "list", "-f", "{{.Dir}}", pkgName).Output()
+	if err != nil {
+		return fmt.Errorf("go list %s: %v", pkgName, err)
+	}
+	pkgDir := strings.TrimSpace(string(out))
+	repo, err := git(pkgDir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return
+	}
+	relPkg, err := filepath.Rel(repo, pkgDir)
+	if err != nil {
+		return
+	}
+
+	if good, err = git(repo, "rev-parse", "--verify", good+"^{commit}"); err != nil {
+		return
+	}
+	if bad, err = git(repo, "rev-parse", "--verify", bad+"^{commit}"); err != nil {
+		return
+	}
+	revs, err := git(repo, "rev-list", "--reverse", "--ancestry-path", good+".."+bad)
+	if err != nil {
+		return
+	}
+	if revs == "" {
+		return fmt.Errorf("%s is not an ancestor of %s", good, bad)
+	}
+	// commits from the oldest to bad
+	commits := strings.Fields(revs)
+
+	worktree := filepath.Join(tempDir, "worktree")
+	if _, err = git(repo, "worktree", "add", "--detach", worktree, bad); err != nil {
+		return
+	}
+	defer func() {
+		if _, rerr := git(repo, "worktree", "remove", "--force", worktree); rerr != nil {
+			log.Printf("ERROR: %v", rerr)
+		}
+	}()
+	if err = os.Chdir(worktree); err != nil {
+		return
+	}
+	pkgName = "./" + filepath.ToSlash(relPkg)
+
+	log.Printf("Bisecting %d commits between %s and %s with seed %d, logs in %s",
+		len(commits), shortHash(good), shortHash(bad), seedNum, tempDir)
+	run := func(commit string) (Seed, error) {
+		if _, err := git(worktree, "checkout", "--quiet", "--detach", commit); err != nil {
+			return Seed{}, err
+		}
+		return runAtCommit(ctx, tempDir, commit, seedNum)
+	}
+	first, failing, suspects, err := bisectCommits(ctx, good, commits, run)
+	if err != nil {
+		return fmt.Errorf("seed %d: %v", seedNum, err)
+	}
+
+	subject, err := git(repo, "log", "-1", "--format=%h %s", first)
+	if err != nil {
+		return
+	}
+	log.Printf("First bad commit: %s", subject)
+	if len(suspects) > 0 {
+		for i, commit := range suspects {
+			suspects[i] = shortHash(commit)
+		}
+		log.Printf("Commits that could not be built were skipped, any of them could be the first bad commit instead: %s",
+			strings.Join(suspects, " "))
+	}
+	if precompile {
+		// the test binary of the last commit tried may not be the one of the first bad commit
+		testBinary = filepath.Join(tempDir, shortHash(first), "sim.test")
+	}
+	log.Printf("To reproduce check out %s and run: %s", shortHash(first),
+		buildCmdString(testname, blocks, period, genesis, failing.ExportState, failing.ExportParams, seedNum))
+	return nil
+}
+
+// bisectCommits looks for the first of commits, ordered from the oldest to the
+// bad one and following good, at which run fails the way it fails at the bad
+// one, and returns it with the seed of its run. The seed has to pass at good,
+// or fail another way; if good does not build it is assumed to pass. Commits
+// that don't build are skipped, the skipped ones between the last passing
+// commit and the first bad one are returned as suspects.
+func bisectCommits(ctx context.Context, good string, commits []string, run func(commit string) (Seed, error)) (
+	first string, failing Seed, suspects []string, err error) {
+	bad := commits[len(commits)-1]
+	seed, err := run(bad)
+	switch {
+	case ctx.Err() != nil:
+		return "", seed, nil, ctx.Err()
+	case err == nil:
+		return "", seed, nil, fmt.Errorf("passes at %s, nothing to bisect", shortHash(bad))
+	case seed.Failure == nil:
+		// the simulation could not be run
+		return "", seed, nil, err
+	case seed.Failure.Category == failureBuild:
+		return "", seed, nil, fmt.Errorf("%s does not build: %s", shortHash(bad), seed.Failure.Detail)
+	}
+	category := seed.Failure.Category
+	log.Printf("%s: FAILED (%s)", shortHash(bad), category)
+
+	goodSeed, err := run(good)
+	switch {
+	case ctx.Err() != nil:
+		return "", goodSeed, nil, ctx.Err()
+	case err == nil:
+		log.Printf("%s: passed", shortHash(good))
+	case goodSeed.Failure == nil:
+		return "", goodSeed, nil, err
+	case goodSeed.Failure.Category == failureBuild:
+		log.Printf("%s: does not build, assuming it passes", shortHash(good))
+	case goodSeed.Failure.Category == category:
+		return "", goodSeed, nil, fmt.Errorf("fails at %s the way it does at %s (%s), nothing to bisect",
+			shortHash(good), shortHash(bad), category)
+	default:
+		log.Printf("%s: FAILED with a different failure (%s)", shortHash(good), goodSeed.Failure.Category)
+	}
+
+	position := make(map[string]int, len(commits))
+	for i, commit := range commits {
+		position[commit] = i
+	}
+	// commits[pass] is the newest commit known to pass (-1 for good), commits[fail]
+	// the oldest known to fail the same way
+	commits = append([]string(nil), commits...)
+	var skipped []string
+	pass, fail, failing := -1, len(commits)-1, seed
+	for fail-pass > 1 {
+		mid := pass + (fail-pass)/2
+		seed, err := run(commits[mid])
+		switch {
+		case ctx.Err() != nil:
+			return "", seed, nil, ctx.Err()
+		case err == nil:
+			log.Printf("%s: passed", shortHash(commits[mid]))
+			pass = mid
+		case seed.Failure == nil:
+			return "", seed, nil, err
+		case seed.Failure.Category == failureBuild:
+			log.Printf("%s: does not build, skipping", shortHash(commits[mid]))
+			skipped = append(skipped, commits[mid])
+			commits = append(commits[:mid], commits[mid+1:]...)
+			fail--
+		case seed.Failure.Category != category:
+			log.Printf("%s: FAILED with a different failure (%s)", shortHash(commits[mid]), seed.Failure.Category)
+			pass = mid
+		default:
+			log.Printf("%s: FAILED (%s)", shortHash(commits[mid]), category)
+			fail, failing = mid, seed
+		}
+	}
+
+	for _, commit := range skipped {
+		if (pass < 0 || position[commit] > position[commits[pass]]) && position[commit] < position[commits[fail]] {
+			suspects = append(suspects, commit)
+		}
+	}
+	sort.Slice(suspects, func(i, j int) bool { return position[suspects[i]] < position[suspects[j]] })
+	return commits[fail], failing, suspects, nil
+}
+
+// runAtCommit runs the seed on commit, which must be checked out in the current directory.
+func runAtCommit(ctx context.Context, tempDir, commit string, seedNum int) (Seed, error) {
+	name := fmt.Sprintf("%s-%s", buildLogFileName(seedNum), shortHash(commit))
+	seed := Seed{
+		Num:          seedNum,
+		Stderr:       filepath.Join(tempDir, name+".stderr"),
+		Stdout:       filepath.Join(tempDir, name+".stdout"),
+		ExportParams: filepath.Join(tempDir, fmt.Sprintf("sim_params-%d-%s.json", seedNum, shortHash(commit))),
+		ExportState:  filepath.Join(tempDir, fmt.Sprintf("sim_state-%d-%s.json", seedNum, shortHash(commit))),
+	}
+	if precompile {
+		dir := filepath.Join(tempDir, shortHash(commit))
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			// not a failure of the commit, seed.Failure stays nil
+			return seed, err
+		}
+		if err := precompileTestBinary(dir, filepath.Join(dir, "build_log")); err != nil {
+			seed.Failure = buildFailure
+			return seed, err
+		}
+	}
+	return runSeed(ctx, 0, seed)
+}
+
+// git runs a git command in dir and returns its trimmed output.
+func git(dir string, args ...string) (string, error) {
+	out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			err = fmt.Errorf("%v: %s", err, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("git %s: %v", strings.Join(args, " "), err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func shortHash(commit string) string {
+	if len(commit) > 10 {
+		return commit[:10]
+	}
+	return commit
+}
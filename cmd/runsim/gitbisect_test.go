@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// commitsRun fails a seed at the commits results maps to a failure category,
+// and passes it at the others.
+func commitsRun(results map[string]string, ran *[]string) func(commit string) (Seed, error) {
+	return func(commit string) (Seed, error) {
+		*ran = append(*ran, commit)
+		category := results[commit]
+		if category == "" {
+			return Seed{}, nil
+		}
+		return Seed{Failed: true, Failure: &failureInfo{Category: category}}, errors.New("exit status 1")
+	}
+}
+
+var bisectHistory = []string{"c1", "c2", "c3", "c4", "c5", "c6", "c7", "c8"}
+
+func TestBisectCommits(t *testing.T) {
+	var ran []string
+	first, failing, suspects, err := bisectCommits(context.Background(), "c0", bisectHistory, commitsRun(map[string]string{
+		"c5": failurePanic, "c6": failurePanic, "c7": failurePanic, "c8": failurePanic,
+	}, &ran))
+	require.NoError(t, err)
+	require.Equal(t, "c5", first)
+	require.Equal(t, failurePanic, failing.Failure.Category)
+	require.Empty(t, suspects)
+	require.Equal(t, []string{"c8", "c0"}, ran[:2])
+
+	// a different failure counts as passing
+	first, _, _, err = bisectCommits(context.Background(), "c0", bisectHistory, commitsRun(map[string]string{
+		"c2": failureTimeout, "c3": failureTimeout, "c6": failurePanic, "c7": failurePanic, "c8": failurePanic,
+	}, &ran))
+	require.NoError(t, err)
+	require.Equal(t, "c6", first)
+}
+
+func TestBisectCommitsNotBuilding(t *testing.T) {
+	// c3 and c4 don't build, the first bad commit could be either of them
+	var ran []string
+	first, _, suspects, err := bisectCommits(context.Background(), "c0", bisectHistory, commitsRun(map[string]string{
+		"c3": failureBuild, "c4": failureBuild, "c5": failurePanic, "c6": failurePanic, "c7": failurePanic, "c8": failurePanic,
+	}, &ran))
+	require.NoError(t, err)
+	require.Equal(t, "c5", first)
+	require.Equal(t, []string{"c3", "c4"}, suspects)
+	require.Equal(t, bisectHistory, []string{"c1", "c2", "c3", "c4", "c5", "c6", "c7", "c8"})
+
+	// skipped commits after the first bad one aren't suspects
+	first, _, suspects, err = bisectCommits(context.Background(), "c0", bisectHistory, commitsRun(map[string]string{
+		"c2": failurePanic, "c3": failurePanic, "c4": failureBuild, "c5": failurePanic, "c6": failurePanic,
+		"c7": failurePanic, "c8": failurePanic,
+	}, &ran))
+	require.NoError(t, err)
+	require.Equal(t, "c2", first)
+	require.Empty(t, suspects)
+
+	// a good commit that doesn't build is assumed to pass
+	first, _, suspects, err = bisectCommits(context.Background(), "c0", bisectHistory, commitsRun(map[string]string{
+		"c0": failureBuild, "c1": failureBuild, "c2": failurePanic, "c3": failurePanic, "c4": failurePanic,
+		"c5": failurePanic, "c6": failurePanic, "c7": failurePanic, "c8": failurePanic,
+	}, &ran))
+	require.NoError(t, err)
+	require.Equal(t, "c2", first)
+	require.Equal(t, []string{"c1"}, suspects)
+
+	_, _, _, err = bisectCommits(context.Background(), "c0", bisectHistory, commitsRun(map[string]string{
+		"c8": failureBuild,
+	}, &ran))
+	require.Error(t, err)
+}
+
+func TestBisectCommitsErrors(t *testing.T) {
+	var ran []string
+	_, _, _, err := bisectCommits(context.Background(), "c0", bisectHistory, commitsRun(nil, &ran))
+	require.EqualError(t, err, "passes at c8, nothing to bisect")
+
+	// the seed has to pass at good
+	ran = nil
+	_, _, _, err = bisectCommits(context.Background(), "c0", bisectHistory, commitsRun(map[string]string{
+		"c0": failurePanic, "c8": failurePanic,
+	}, &ran))
+	require.EqualError(t, err, "fails at c0 the way it does at c8 (panic), nothing to bisect")
+	require.Equal(t, []string{"c8", "c0"}, ran)
+
+	// a simulation that could not be run stops the bisection
+	calls := 0
+	_, _, _, err = bisectCommits(context.Background(), "c0", bisectHistory, func(commit string) (Seed, error) {
+		if calls++; calls == 1 {
+			return Seed{Failed: true, Failure: &failureInfo{Category: failurePanic}}, errors.New("exit status 1")
+		}
+		return Seed{}, errors.New("mkdir: permission denied")
+	})
+	require.EqualError(t, err, "mkdir: permission denied")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, _, _, err = bisectCommits(ctx, "c0", bisectHistory, commitsRun(map[string]string{"c8": failurePanic}, &ran))
+	require.Equal(t, context.Canceled, err)
+}
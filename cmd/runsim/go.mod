@@ -3,7 +3,12 @@ module github.com/cosmos/tools/cmd/runsim
 go 1.13
 
 require (
+	github.com/BurntSushi/toml v0.3.1
 	github.com/aws/aws-sdk-go v1.23.17
 	github.com/cosmos/tools/lib/runsimgh v1.0.0
 	github.com/cosmos/tools/lib/runsimslack v1.0.0
+	github.com/klauspost/compress v1.11.13
+	github.com/stretchr/testify v1.4.0
+	go.etcd.io/bbolt v1.3.5
+	gopkg.in/yaml.v2 v2.2.2
 )
@@ -0,0 +1,348 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// bucket of the history database holding a record per run, keyed by start
+// time and host so that the runs sort chronologically
+var historyBucket = []byte("runs")
+
+// how long to wait for another runsim holding the history database
+const historyLockTimeout = time.Minute
+
+// historyRun is the record of a run in the history database.
+type historyRun struct {
+	Time     time.Time     `json:"time"`
+	Commit   string        `json:"commit"`
+	TestName string        `json:"test_name"`
+	Package  string        `json:"package"`
+	Period   string        `json:"period"`
+	Genesis  string        `json:"genesis,omitempty"`
+	HostId   string        `json:"host_id,omitempty"`
+	Seeds    []historySeed `json:"seeds"`
+}
+
+type historySeed struct {
+	Seed     int           `json:"seed"`
+	Cell     string        `json:"cell,omitempty"`
+	Blocks   string        `json:"blocks"`
+	Status   string        `json:"status"`
+	Duration time.Duration `json:"duration"`
+	MaxRSS   int64         `json:"max_rss_bytes"`
+	Category string        `json:"category,omitempty"`
+}
+
+func openHistory(path string, readOnly bool) (*bolt.DB, error) {
+	return bolt.Open(path, 0666, &bolt.Options{Timeout: historyLockTimeout, ReadOnly: readOnly})
+}
+
+// recordHistory appends the results of a run to the history database.
+func recordHistory(path string, started time.Time, results []Seed) (err error) {
+	run := historyRun{
+		Time:     started,
+		Commit:   sdkCommit(),
+		TestName: testname,
+		Package:  pkgName,
+		Period:   period,
+		Genesis:  genesis,
+		HostId:   hostId,
+		Seeds:    make([]historySeed, 0, len(results)),
+	}
+	for _, seed := range results {
+		_, blocks := seedCell(seed)
+		record := historySeed{
+			Seed:     seed.Num,
+			Cell:     seed.Cell,
+			Blocks:   blocks,
+			Status:   seed.status(),
+			Duration: seed.Duration,
+			MaxRSS:   seed.Usage.MaxRSS,
+		}
+		if seed.Failure != nil {
+			record.Category = seed.Failure.Category
+		}
+		run.Seeds = append(run.Seeds, record)
+	}
+	value, err := json.Marshal(run)
+	if err != nil {
+		return
+	}
+
+	db, err := openHistory(path, false)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if cerr := db.Close(); err == nil {
+			err = cerr
+		}
+	}()
+	return db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(historyBucket)
+		if err != nil {
+			return err
+		}
+		key := fmt.Sprintf("%s/%s", started.UTC().Format(time.RFC3339Nano), hostId)
+		return bucket.Put([]byte(key), value)
+	})
+}
+
+// loadHistory returns the runs recorded in the history database, oldest first.
+func loadHistory(path string) (runs []historyRun, err error) {
+	if _, err = os.Stat(path); err != nil {
+		return
+	}
+	db, err := openHistory(path, true)
+	if err != nil {
+		return
+	}
+	defer db.Close()
+
+	err = db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(historyBucket)
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(key, value []byte) error {
+			var run historyRun
+			if err := json.Unmarshal(value, &run); err != nil {
+				return fmt.Errorf("run %s: %v", key, err)
+			}
+			runs = append(runs, run)
+			return nil
+		})
+	})
+	return
+}
+
+// sdkCommit returns the commit of the repository containing -SimAppPkg, the
+// one the simulations ran at.
+func sdkCommit() string {
+	out, err := exec.Command("go", "list", "-f", "{{.Dir}}", pkgName).Output()
+	if err == nil {
+		var commit string
+		if commit, err = git(strings.TrimSpace(string(out)), "rev-parse", "HEAD"); err == nil {
+			return commit
+		}
+	}
+	log.Printf("WARNING: the commit of %s is unknown, recording it as such in the history: %v", pkgName, err)
+	return "unknown"
+}
+
+// runHistory implements "runsim history [-HistoryDB file] [-Test name] [-Commits n] [-Regression factor]".
+// It shows the pass rate of every seed at each of the last commits and the
+// seeds whose duration regressed at the last commit.
+func runHistory(args []string) {
+	fs := flag.NewFlagSet("history", flag.ExitOnError)
+	path := fs.String("HistoryDB", os.Getenv("RUNSIM_HISTORY_DB"), "history database file (default: $RUNSIM_HISTORY_DB)")
+	test := fs.String("Test", "", "only show the results of this test")
+	numCommits := fs.Int("Commits", 8, "number of most recent commits shown")
+	regression := fs.Float64("Regression", 1.25, "report seeds whose mean duration at the last commit exceeds the one of the earlier commits by this factor")
+	fs.Usage = func() {
+		_, _ = fmt.Fprintf(fs.Output(), "Usage: %s history [-HistoryDB file] [-Test name] [-Commits n] [-Regression factor]\n",
+			filepath.Base(os.Args[0]))
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() != 0 || *path == "" || *numCommits < 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	runs, err := loadHistory(*path)
+	if err != nil {
+		log.Fatalf("ERROR: loadHistory: %v", err)
+	}
+	h := buildHistory(runs, *test, *numCommits)
+	if len(h.commits) == 0 {
+		log.Fatalf("No runs recorded in %s", *path)
+	}
+	if err := h.writePassRates(os.Stdout); err != nil {
+		log.Fatalf("ERROR: %v", err)
+	}
+	if err := h.writeRegressions(os.Stdout, *regression); err != nil {
+		log.Fatalf("ERROR: %v", err)
+	}
+}
+
+// seedHistory holds the results of the last commits by seed. A seed is told
+// apart by its test, matrix cell and block count as well.
+type seedHistory struct {
+	commits []string
+	rows    []historyRow
+}
+
+type historyRow struct {
+	test, blocks string
+	seed         Seed
+	// results by commit
+	results map[string][]historySeed
+}
+
+func buildHistory(runs []historyRun, test string, numCommits int) seedHistory {
+	sort.SliceStable(runs, func(i, j int) bool { return runs[i].Time.Before(runs[j].Time) })
+
+	// commits in the order they were first simulated
+	var commits []string
+	seen := make(map[string]bool)
+	for _, run := range runs {
+		if (test == "" || run.TestName == test) && !seen[run.Commit] {
+			seen[run.Commit] = true
+			commits = append(commits, run.Commit)
+		}
+	}
+	if len(commits) > numCommits {
+		commits = commits[len(commits)-numCommits:]
+	}
+	shown := make(map[string]bool)
+	for _, commit := range commits {
+		shown[commit] = true
+	}
+
+	h := seedHistory{commits: commits}
+	index := make(map[string]int)
+	for _, run := range runs {
+		if test != "" && run.TestName != test || !shown[run.Commit] {
+			continue
+		}
+		for _, result := range run.Seeds {
+			key := fmt.Sprintf("%s/%d/%s/%s", run.TestName, result.Seed, result.Cell, result.Blocks)
+			i, ok := index[key]
+			if !ok {
+				i = len(h.rows)
+				index[key] = i
+				h.rows = append(h.rows, historyRow{
+					test:    run.TestName,
+					blocks:  result.Blocks,
+					seed:    Seed{Num: result.Seed, Cell: result.Cell},
+					results: make(map[string][]historySeed),
+				})
+			}
+			h.rows[i].results[run.Commit] = append(h.rows[i].results[run.Commit], result)
+		}
+	}
+	sort.Slice(h.rows, func(i, j int) bool {
+		a, b := h.rows[i], h.rows[j]
+		if a.test != b.test {
+			return a.test < b.test
+		}
+		if a.seed.Num != b.seed.Num {
+			return a.seed.Num < b.seed.Num
+		}
+		if a.seed.Cell != b.seed.Cell {
+			return a.seed.Cell < b.seed.Cell
+		}
+		return a.blocks < b.blocks
+	})
+	return h
+}
+
+func passed(result historySeed) bool {
+	return result.Status == seedPassed || result.Status == seedFlaky
+}
+
+// writePassRates writes a table of the passed and total runs of every seed at
+// each commit, the oldest first.
+func (h seedHistory) writePassRates(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "Pass rates by commit, oldest first\nTEST\tSEED\tBLOCKS")
+	for _, commit := range h.commits {
+		fmt.Fprintf(tw, "\t%s", shortHash(commit))
+	}
+	fmt.Fprintf(tw, "\tTOTAL\tLAST FAILURE\n")
+
+	for _, row := range h.rows {
+		fmt.Fprintf(tw, "%s\t%s\t%s", row.test, row.seed.name(), row.blocks)
+		var pass, total int
+		var lastFailure string
+		for _, commit := range h.commits {
+			results := row.results[commit]
+			if len(results) == 0 {
+				fmt.Fprint(tw, "\t-")
+				continue
+			}
+			var n int
+			for _, result := range results {
+				if passed(result) {
+					n++
+				} else {
+					lastFailure = fmt.Sprintf("%s at %s", result.Category, shortHash(commit))
+				}
+			}
+			fmt.Fprintf(tw, "\t%d/%d", n, len(results))
+			pass += n
+			total += len(results)
+		}
+		fmt.Fprintf(tw, "\t%.0f%%\t%s\n", 100*float64(pass)/float64(total), lastFailure)
+	}
+	fmt.Fprintln(tw)
+	return tw.Flush()
+}
+
+// writeRegressions lists the seeds whose mean duration over the passing runs
+// at the last commit exceeds the mean at the earlier commits by factor.
+func (h seedHistory) writeRegressions(w io.Writer, factor float64) error {
+	if len(h.commits) < 2 {
+		_, err := fmt.Fprintln(w, "Duration regressions: only one commit recorded")
+		return err
+	}
+	last := h.commits[len(h.commits)-1]
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "Duration regressions at %s (over %.2fx the earlier commits)\n", shortHash(last), factor)
+	var found bool
+	for _, row := range h.rows {
+		var before []historySeed
+		for _, commit := range h.commits[:len(h.commits)-1] {
+			before = append(before, row.results[commit]...)
+		}
+		baseline, ok := meanDuration(before)
+		if !ok {
+			continue
+		}
+		latest, ok := meanDuration(row.results[last])
+		if !ok || float64(latest) <= float64(baseline)*factor {
+			continue
+		}
+		if !found {
+			fmt.Fprintln(tw, "TEST\tSEED\tBLOCKS\tBEFORE\tNOW\tCHANGE")
+			found = true
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t+%.0f%%\n", row.test, row.seed.name(), row.blocks,
+			baseline.Round(time.Second), latest.Round(time.Second), 100*(float64(latest)/float64(baseline)-1))
+	}
+	if !found {
+		fmt.Fprintln(tw, "none")
+	}
+	return tw.Flush()
+}
+
+// meanDuration returns the mean duration of the passing runs, failed runs end early.
+func meanDuration(results []historySeed) (time.Duration, bool) {
+	var total time.Duration
+	var n int
+	for _, result := range results {
+		if passed(result) {
+			total += result.Duration
+			n++
+		}
+	}
+	if n == 0 {
+		return 0, false
+	}
+	return total / time.Duration(n), true
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordHistory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "runsim-history")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	savedTestname, savedBlocks := testname, blocks
+	defer func() { testname, blocks = savedTestname, savedBlocks }()
+	testname, blocks = "TestFullAppSimulation", "100"
+
+	path := filepath.Join(dir, "history.db")
+	started := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	require.NoError(t, recordHistory(path, started, []Seed{
+		{Num: 1, Duration: time.Minute},
+		{Num: 2, Failed: true, Failure: &failureInfo{Category: failurePanic}},
+	}))
+	require.NoError(t, recordHistory(path, started.Add(time.Hour), []Seed{{Num: 1, Duration: time.Minute}}))
+
+	runs, err := loadHistory(path)
+	require.NoError(t, err)
+	require.Len(t, runs, 2)
+	require.True(t, runs[0].Time.Equal(started))
+	require.Equal(t, []historySeed{
+		{Seed: 1, Blocks: "100", Status: seedPassed, Duration: time.Minute},
+		{Seed: 2, Blocks: "100", Status: seedFailed, Category: failurePanic},
+	}, runs[0].Seeds)
+}
+
+func TestSeedHistory(t *testing.T) {
+	start := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	run := func(hours int, commit string, seeds ...historySeed) historyRun {
+		return historyRun{Time: start.Add(time.Duration(hours) * time.Hour), Commit: commit, TestName: "TestSim", Seeds: seeds}
+	}
+	pass := func(seed int, d time.Duration) historySeed {
+		return historySeed{Seed: seed, Blocks: "100", Status: seedPassed, Duration: d}
+	}
+	fail := historySeed{Seed: 2, Blocks: "100", Status: seedFailed, Category: failureInvariant}
+
+	h := buildHistory([]historyRun{
+		run(3, "cccccccccccc", pass(1, 3*time.Minute), fail),
+		run(0, "aaaaaaaaaaaa", pass(1, time.Minute), pass(2, time.Minute)),
+		run(1, "bbbbbbbbbbbb", pass(1, time.Minute), pass(2, time.Minute)),
+		run(2, "bbbbbbbbbbbb", pass(1, time.Minute), fail),
+		{Time: start, Commit: "dddddddddddd", TestName: "TestOther", Seeds: []historySeed{pass(1, time.Minute)}},
+	}, "TestSim", 2)
+	require.Equal(t, []string{"bbbbbbbbbbbb", "cccccccccccc"}, h.commits)
+
+	var out bytes.Buffer
+	require.NoError(t, h.writePassRates(&out))
+	require.Equal(t, `Pass rates by commit, oldest first
+TEST     SEED  BLOCKS  bbbbbbbbbb  cccccccccc  TOTAL  LAST FAILURE
+TestSim  1     100     2/2         1/1         100%   
+TestSim  2     100     1/2         0/1         33%    invariant broken at cccccccccc
+
+`, out.String())
+
+	out.Reset()
+	require.NoError(t, h.writeRegressions(&out, 1.25))
+	require.Equal(t, `Duration regressions at cccccccccc (over 1.25x the earlier commits)
+TEST     SEED  BLOCKS  BEFORE  NOW   CHANGE
+TestSim  1     100     1m0s    3m0s  +200%
+`, out.String())
+}
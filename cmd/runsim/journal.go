@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// seed states recorded in the journal
+const (
+	seedPending     = "pending"
+	seedRunning     = "running"
+	seedPassed      = "passed"
+	seedFailed      = "failed"
+	seedTimedOut    = "timed out"
+	seedFlaky       = "flaky"
+	seedInterrupted = "interrupted"
+)
+
+// The journal is an append-only file of JSON lines, one per seed state transition.
+// The last entry recorded for a seed wins, so a seed that was interrupted, or still
+// running when the host went down, gets rerun on -Resume.
+type journalEntry struct {
+	Seed         int           `json:"seed"`
+	Cell         string        `json:"cell,omitempty"`
+	Status       string        `json:"status"`
+	ExitCode     int           `json:"exit_code"`
+	Duration     time.Duration `json:"duration"`
+	Usage        resourceUsage `json:"usage"`
+	Stdout       string        `json:"stdout"`
+	Stderr       string        `json:"stderr"`
+	ExportParams string        `json:"export_params"`
+	ExportState  string        `json:"export_state"`
+	Attempts     int           `json:"attempts,omitempty"`
+	PrevAttempts []string      `json:"prev_attempts,omitempty"`
+	Runs         []string      `json:"runs,omitempty"`
+	Stage        string        `json:"stage,omitempty"`
+	ImportState  string        `json:"import_state,omitempty"`
+	ImportParams string        `json:"import_params,omitempty"`
+	Stages       []string      `json:"stages,omitempty"`
+	Failure      *failureInfo  `json:"failure,omitempty"`
+	Time         time.Time     `json:"time"`
+}
+
+type seedJournal struct {
+	mtx  sync.Mutex
+	file *os.File
+}
+
+func openJournal(path string) (*seedJournal, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0666)
+	if err != nil {
+		return nil, err
+	}
+	return &seedJournal{file: file}, nil
+}
+
+// record appends the seed's current state to the journal and syncs it to disk,
+// so that the entry survives the host being reclaimed right afterwards.
+func (j *seedJournal) record(seed Seed, status string) {
+	line, err := json.Marshal(journalEntry{
+		Seed:         seed.Num,
+		Cell:         seed.Cell,
+		Status:       status,
+		ExitCode:     seed.ExitCode,
+		Duration:     seed.Duration,
+		Usage:        seed.Usage,
+		Stdout:       seed.Stdout,
+		Stderr:       seed.Stderr,
+		ExportParams: seed.ExportParams,
+		ExportState:  seed.ExportState,
+		Attempts:     seed.Attempts,
+		PrevAttempts: seed.PrevAttempts,
+		Runs:         seed.Runs,
+		Stage:        seed.Stage,
+		ImportState:  seed.ImportState,
+		ImportParams: seed.ImportParams,
+		Stages:       seed.Stages,
+		Failure:      seed.Failure,
+		Time:         time.Now(),
+	})
+	if err != nil {
+		log.Printf("ERROR: journal: %v", err)
+		return
+	}
+
+	j.mtx.Lock()
+	defer j.mtx.Unlock()
+	if _, err := j.file.Write(append(line, '\n')); err != nil {
+		log.Printf("ERROR: journal: %v", err)
+		return
+	}
+	if err := j.file.Sync(); err != nil {
+		log.Printf("ERROR: journal: %v", err)
+	}
+}
+
+func (j *seedJournal) Name() string {
+	return j.file.Name()
+}
+
+func (j *seedJournal) Close() error {
+	j.mtx.Lock()
+	defer j.mtx.Unlock()
+	return j.file.Close()
+}
+
+// loadJournal returns the last recorded entry of every seed in the journal,
+// in the order the seeds first appeared.
+func loadJournal(path string) ([]journalEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	// a seed is recorded once per matrix cell
+	type seedKey struct {
+		seed int
+		cell string
+	}
+	var order []seedKey
+	last := make(map[seedKey]journalEntry)
+	sc := bufio.NewScanner(file)
+	for lineNum := 1; sc.Scan(); lineNum++ {
+		var entry journalEntry
+		if err := json.Unmarshal(sc.Bytes(), &entry); err != nil {
+			// the last line may be truncated if the host died mid-write
+			log.Printf("WARNING: %s:%d: skipping malformed journal entry: %v", path, lineNum, err)
+			continue
+		}
+		key := seedKey{entry.Seed, entry.Cell}
+		if _, ok := last[key]; !ok {
+			order = append(order, key)
+		}
+		last[key] = entry
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	entries := make([]journalEntry, len(order))
+	for i, key := range order {
+		entries[i] = last[key]
+	}
+	return entries, nil
+}
+
+// resumeSeeds splits the seeds recorded in a journal into the ones that still
+// need to run and the ones that already finished in a previous run.
+func resumeSeeds(path string) (pending, finished []Seed, err error) {
+	entries, err := loadJournal(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(entries) == 0 {
+		return nil, nil, fmt.Errorf("journal %s contains no seeds", path)
+	}
+
+	for _, entry := range entries {
+		switch entry.Status {
+		case seedPassed, seedFailed, seedTimedOut, seedFlaky:
+			finished = append(finished, Seed{
+				Num:          entry.Seed,
+				Cell:         entry.Cell,
+				Stdout:       entry.Stdout,
+				Stderr:       entry.Stderr,
+				ExportParams: entry.ExportParams,
+				ExportState:  entry.ExportState,
+				ExitCode:     entry.ExitCode,
+				Duration:     entry.Duration,
+				Usage:        entry.Usage,
+				Attempts:     entry.Attempts,
+				PrevAttempts: entry.PrevAttempts,
+				Runs:         entry.Runs,
+				Stage:        entry.Stage,
+				ImportState:  entry.ImportState,
+				ImportParams: entry.ImportParams,
+				Stages:       entry.Stages,
+				Failed:       entry.Status == seedFailed || entry.Status == seedTimedOut,
+				TimedOut:     entry.Status == seedTimedOut,
+				Flaky:        entry.Status == seedFlaky,
+				Failure:      entry.Failure,
+			})
+		default:
+			pending = append(pending, Seed{Num: entry.Seed, Cell: entry.Cell})
+		}
+	}
+	return
+}
+
+// exitCode extracts the exit status of a simulation process from the error returned by exec.Cmd.
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
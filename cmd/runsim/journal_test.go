@@ -0,0 +1,101 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestJournalRecordLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "runsim-journal")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	fileName := filepath.Join(dir, "journal")
+	journal, err := openJournal(fileName)
+	require.NoError(t, err)
+	failed := Seed{Num: 7, Failed: true, ExitCode: 2, Duration: time.Minute, Attempts: 2,
+		Stdout: "sim_log-7.stdout", PrevAttempts: []string{"sim_log-7.stdout-retry-1"},
+		Failure: &failureInfo{Category: failurePanic, Detail: "boom"}}
+	journal.record(Seed{Num: 7}, seedPending)
+	journal.record(Seed{Num: 1}, seedPending)
+	journal.record(Seed{Num: 7}, seedRunning)
+	journal.record(failed, failed.status())
+	require.NoError(t, journal.Close())
+
+	// the last entry of a seed wins, seeds keep the order they first appeared in
+	entries, err := loadJournal(fileName)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.Equal(t, 7, entries[0].Seed)
+	require.Equal(t, seedFailed, entries[0].Status)
+	require.Equal(t, 2, entries[0].ExitCode)
+	require.Equal(t, time.Minute, entries[0].Duration)
+	require.Equal(t, failed.PrevAttempts, entries[0].PrevAttempts)
+	require.Equal(t, failed.Failure, entries[0].Failure)
+	require.Equal(t, 1, entries[1].Seed)
+	require.Equal(t, seedPending, entries[1].Status)
+
+	// reopening appends to the journal
+	journal, err = openJournal(fileName)
+	require.NoError(t, err)
+	journal.record(Seed{Num: 1}, seedPassed)
+	require.NoError(t, journal.Close())
+	entries, err = loadJournal(fileName)
+	require.NoError(t, err)
+	require.Equal(t, seedPassed, entries[1].Status)
+}
+
+func TestLoadJournalTruncatedLine(t *testing.T) {
+	dir, err := ioutil.TempDir("", "runsim-journal")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// the host died while the last entry was being written
+	fileName := filepath.Join(dir, "journal")
+	require.NoError(t, ioutil.WriteFile(fileName, []byte(`{"seed":1,"status":"pending"}
+{"seed":2,"status":"pending"}
+{"seed":1,"status":"passed"}
+{"seed":2,"status":"pas`), 0644))
+	entries, err := loadJournal(fileName)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.Equal(t, seedPassed, entries[0].Status)
+	require.Equal(t, seedPending, entries[1].Status)
+}
+
+func TestResumeSeeds(t *testing.T) {
+	dir, err := ioutil.TempDir("", "runsim-journal")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	fileName := filepath.Join(dir, "journal")
+	require.NoError(t, ioutil.WriteFile(fileName, []byte(`{"seed":1,"status":"passed"}
+{"seed":2,"status":"failed","exit_code":1,"failure":{"category":"panic"}}
+{"seed":3,"status":"timed out"}
+{"seed":4,"status":"flaky","attempts":2}
+{"seed":5,"status":"pending"}
+{"seed":6,"status":"running"}
+{"seed":7,"status":"interrupted"}
+`), 0644))
+
+	pending, finished, err := resumeSeeds(fileName)
+	require.NoError(t, err)
+	require.Equal(t, []Seed{{Num: 5}, {Num: 6}, {Num: 7}}, pending)
+	require.Len(t, finished, 4)
+	statuses := make([]string, len(finished))
+	for i, seed := range finished {
+		statuses[i] = seed.status()
+	}
+	require.Equal(t, []string{seedPassed, seedFailed, seedTimedOut, seedFlaky}, statuses)
+	require.Equal(t, &failureInfo{Category: failurePanic}, finished[1].Failure)
+	require.Equal(t, 2, finished[3].Attempts)
+
+	require.NoError(t, ioutil.WriteFile(fileName, nil, 0644))
+	_, _, err = resumeSeeds(fileName)
+	require.Error(t, err)
+}
@@ -2,13 +2,14 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
 	"os"
-	"os/exec"
 	"os/signal"
 	"path/filepath"
 	"runtime"
@@ -29,6 +30,9 @@ const (
 )
 
 var (
+	// time a timed out simulation gets to dump its goroutines before it's killed
+	quitTimeout = 10 * time.Second
+
 	// default seeds
 	seeds = []int{
 		1, 2, 4, 7, 32, 123, 124, 582, 1893, 2989,
@@ -50,6 +54,9 @@ var (
 	// log stuff
 	runsimLogFile *os.File
 	timeout       time.Duration
+
+	// per-seed outcomes, persisted so that interrupted runs can be resumed
+	journal *seedJournal
 )
 
 type Seed struct {
@@ -58,7 +65,53 @@ type Seed struct {
 	Stderr       string
 	ExportParams string
 	ExportState  string
+	ExitCode     int
+	Duration     time.Duration
+	Usage        resourceUsage
+	Attempts     int
 	Failed       bool
+	Flaky        bool
+	Interrupted  bool
+	TimedOut     bool
+	Failure      *failureInfo
+
+	// logs of the failed attempts of a retried seed
+	PrevAttempts []string
+
+	// index of the run of a determinism check, from 1, and the logs and exports of the other runs
+	Run  int
+	Runs []string
+
+	// stage of an import/export round trip, the genesis of the state and the params it
+	// imports, and the logs and exports of the stages before it
+	Stage                     string
+	ImportState, ImportParams string
+	Stages                    []string
+
+	// label of the matrix cell the seed runs in, empty outside of matrix runs
+	Cell string
+}
+
+// name identifies the seed in logs and summaries, with its matrix cell if any.
+func (seed Seed) name() string {
+	if seed.Cell == "" {
+		return strconv.Itoa(seed.Num)
+	}
+	return fmt.Sprintf("%d [%s]", seed.Num, seed.Cell)
+}
+
+func (seed Seed) status() string {
+	switch {
+	case seed.Interrupted:
+		return seedInterrupted
+	case seed.TimedOut:
+		return seedTimedOut
+	case seed.Failed:
+		return seedFailed
+	case seed.Flaky:
+		return seedFlaky
+	}
+	return seedPassed
 }
 
 func init() {
@@ -72,85 +125,245 @@ func init() {
 }
 
 func main() {
+	// statediff and history only read files, they need no log directory
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "statediff":
+			runStateDiff(os.Args[2:])
+			return
+		case "history":
+			runHistory(os.Args[2:])
+			return
+		}
+	}
+	started := time.Now()
+
 	tempDir, err := ioutil.TempDir("", "sim-logs-")
 	if err != nil {
 		log.Fatalf("ERROR: ioutil.TempDir: %v", err)
 	}
 
-	okZip = filepath.Join(tempDir, "ok.zip")
-	failedZip = filepath.Join(tempDir, "failed.zip")
-	exportsZip = filepath.Join(tempDir, "exports.zip")
-
 	runsimLogFile, err = os.OpenFile(filepath.Join(tempDir, "runsim_log"), os.O_RDWR|os.O_APPEND|os.O_CREATE, 0666)
 	if err != nil {
 		log.Fatalf("ERROR: os.OpenFile: %v", err)
 	}
 	log.SetOutput(io.MultiWriter(os.Stdout, runsimLogFile))
 
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "bisect":
+			runBisect(tempDir, os.Args[2:])
+			return
+		case "gitbisect":
+			runGitBisect(tempDir, os.Args[2:])
+			return
+		}
+	}
+
 	flag.Parse()
-	if flag.NArg() != 3 {
+	args := flag.Args()
+	if profilePath != "" {
+		if args, err = applyProfile(flag.CommandLine, profilePath, args); err != nil {
+			log.Fatalf("ERROR: applyProfile: %v", err)
+		}
+		log.Printf("Using run profile %s", profilePath)
+	}
+	if len(args) != 3 {
 		log.Fatal("ERROR: wrong number of arguments")
 	}
+	if err := validateReportFormat(reportFormat); err != nil {
+		log.Fatalf("ERROR: %v", err)
+	}
+	if err := validateArtifactStore(); err != nil {
+		log.Fatalf("ERROR: %v", err)
+	}
+	if err := validateArchiveFormat(archiveFormat); err != nil {
+		log.Fatalf("ERROR: %v", err)
+	}
+	if determinismRuns != 0 && determinismRuns < 2 {
+		log.Fatalf("ERROR: -Determinism must be at least 2, got %d", determinismRuns)
+	}
+	okArchive = filepath.Join(tempDir, "ok."+archiveFormat)
+	failedArchive = filepath.Join(tempDir, "failed."+archiveFormat)
+	interruptedArchive = filepath.Join(tempDir, "interrupted."+archiveFormat)
+	exportsArchive = filepath.Join(tempDir, "exports."+archiveFormat)
+	// archives are built as the seeds finish if they are published, or kept if asked for
+	if publishing() || flagGiven("ArchiveFormat") {
+		archiver = newSeedArchiver(archiveFormat)
+	}
+	if reportFormat != "" && reportFile == "" {
+		reportFile = filepath.Join(tempDir, "report."+reportFormat)
+	}
+	if err := initCmdTemplate(cmdTemplateText, cmdTemplateFile); err != nil {
+		log.Fatalf("ERROR: initCmdTemplate: %v", err)
+	}
 
 	// initialise common test parameters
-	blocks = flag.Arg(0)
-	period = flag.Arg(1)
-	testname = flag.Arg(2)
+	blocks = args[0]
+	period = args[1]
+	testname = args[2]
 
-	if notifyGithub || notifySlack {
+	if matrix, genesis, err = buildMatrix(genesis, blocks); err != nil {
+		log.Fatalf("ERROR: buildMatrix: %v", err)
+	}
+	if matrix != nil {
+		labels := make([]string, len(matrix))
+		for i, cell := range matrix {
+			labels[i] = cell.Label
+		}
+		log.Printf("Running every seed in %d matrix cells: %s", len(matrix), strings.Join(labels, ", "))
+	}
+
+	if importExport {
+		if importStages, err = parseImportTests(importTests); err != nil {
+			log.Fatalf("ERROR: parseImportTests: %v", err)
+		}
+		if err := checkImportCmd(); err != nil {
+			log.Fatalf("ERROR: -ImportExport: %v", err)
+		}
+		names := make([]string, len(importStages))
+		for i, stage := range importStages {
+			names[i] = fmt.Sprintf("%s (%s)", stage.Name, stage.Test)
+		}
+		log.Printf("Running import/export round trips: %s", strings.Join(names, ", "))
+	}
+
+	if publishing() {
 		configIntegration()
 	}
 
-	seedOverrideList = strings.TrimSpace(seedOverrideList)
-	if seedOverrideList != "" {
-		seeds, err = buildSeedList(seedOverrideList)
+	if seeds, err = collectSeeds(seeds); err != nil {
+		if notifyGithub || notifySlack {
+			pushNotification(true, fmt.Sprintf("Host %s: ERROR: collectSeeds: %v", hostId, err))
+		}
+		log.Fatal(err)
+	}
+
+	// the seeds to run, once in every cell of a matrix run, and the seeds that
+	// already finished in the run being resumed
+	pending := matrixSeeds(seeds)
+	var finishedSeeds []Seed
+	if resumePath != "" {
+		journalPath = resumePath
+		if pending, finishedSeeds, err = resumeSeeds(resumePath); err == nil {
+			err = checkCells(append(append([]Seed(nil), pending...), finishedSeeds...))
+		}
 		if err != nil {
 			if notifyGithub || notifySlack {
-				pushNotification(true, fmt.Sprintf("Host %s: ERROR: buildSeedList: %v", hostId, err))
+				pushNotification(true, fmt.Sprintf("Host %s: ERROR: resumeSeeds: %v", hostId, err))
 			}
 			log.Fatal(err)
 		}
+		log.Printf("Resuming from %s: %d seeds finished, %d seeds pending", resumePath, len(finishedSeeds), len(pending))
+		for _, seed := range finishedSeeds {
+			archiver.add(seed)
+		}
+	}
+
+	if journalPath == "" {
+		journalPath = filepath.Join(tempDir, "journal")
+	}
+	if journal, err = openJournal(journalPath); err != nil {
+		log.Fatalf("ERROR: openJournal: %v", err)
+	}
+	log.Printf("Recording seed results to %s", journal.Name())
+
+	if determinismRuns > 0 {
+		determinism = newDeterminismChecker(determinismRuns)
+		log.Printf("Checking determinism: every seed runs %d times", determinismRuns)
 	}
 
-	seedQueue := make(chan Seed, len(seeds))
-	for _, seed := range seeds {
-		seedQueue <- Seed{
-			Num:          seed,
-			Stderr:       filepath.Join(tempDir, buildLogFileName(seed)+".stderr"),
-			Stdout:       filepath.Join(tempDir, buildLogFileName(seed)+".stdout"),
-			ExportParams: filepath.Join(tempDir, fmt.Sprintf("sim_params-%d.json", seed)),
-			ExportState:  filepath.Join(tempDir, fmt.Sprintf("sim_state-%d.json", seed)),
+	queueSize := len(pending)
+	if determinism != nil {
+		queueSize *= determinismRuns
+	}
+	seedQueue := make(chan Seed, queueSize)
+	for _, s := range pending {
+		logName, exportName := buildLogFileName(s.Num), fmt.Sprint(s.Num)
+		if s.Cell != "" {
+			logName += "-" + s.Cell
+			exportName += "-" + s.Cell
+		}
+		s.Stderr = filepath.Join(tempDir, logName+".stderr")
+		s.Stdout = filepath.Join(tempDir, logName+".stdout")
+		s.ExportParams = filepath.Join(tempDir, fmt.Sprintf("sim_params-%s.json", exportName))
+		s.ExportState = filepath.Join(tempDir, fmt.Sprintf("sim_state-%s.json", exportName))
+		journal.record(s, seedPending)
+		if determinism == nil {
+			seedQueue <- s
+			continue
+		}
+		for _, run := range determinism.seedRuns(s) {
+			seedQueue <- run
 		}
 	}
 	close(seedQueue)
 
-	// jobs cannot be > len(seeds)
-	if jobs > len(seeds) {
-		jobs = len(seeds)
+	if precompile && len(pending) > 0 {
+		buildLog := filepath.Join(tempDir, "build_log")
+		if err := precompileTestBinary(tempDir, buildLog); err != nil {
+			log.Printf("ERROR: precompileTestBinary: %v", err)
+			reportBuildFailure(buildLog)
+		}
 	}
 
-	// setup signal handling
+	// jobs cannot be > len(seedQueue)
+	if jobs > len(seedQueue) {
+		jobs = len(seedQueue)
+	}
+
+	if memSchedule {
+		if memLimit <= 0 || memLimit > 1 {
+			log.Fatalf("ERROR: -MemLimit must be in (0, 1], got %v", memLimit)
+		}
+		estimate, err := parseBytes(seedMem)
+		if err != nil {
+			log.Fatalf("ERROR: -SeedMem: %v", err)
+		}
+		memSched = newMemScheduler(jobs, memLimit, estimate)
+		log.Printf("Memory scheduling enabled: limit %.0f%% of host memory, seed estimate %s",
+			memLimit*100, formatBytes(estimate))
+	}
+
+	// setup signal handling: the first signal cancels the run and gives the running seeds
+	// a grace period to exit, the second one kills them right away
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 	sigs := make(chan os.Signal, 1)
 	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
 
-	results := make(chan Seed, len(seeds))
+	results := make(chan Seed, len(pending))
+	// seeds taken from the queue after the run was interrupted
+	unstarted := make(chan Seed, queueSize)
 	go func() {
-		<-sigs
-		fmt.Println()
+		sig := <-sigs
+		log.Printf("Received %s, stopping the simulations (grace period %s)...", sig, gracePeriod)
+		cancel()
 
-		// drain the queue
-		log.Printf("Draining seeds queue...")
-		for seed := range seedQueue {
-			log.Printf("%d", seed.Num)
-		}
+		<-sigs
 		log.Printf("Kill all remaining processes...")
 		killAllProcs()
+		log.Printf("Seed results were recorded to %s, rerun with -Resume to continue", journal.Name())
 		if notifyGithub || notifySlack {
 			uploadLogAndExit()
 		}
 		os.Exit(1)
 	}()
 
+	progress = newProgressTracker(jobs, len(pending))
+	if listenAddr != "" {
+		go serveStatus(listenAddr, seedQueue)
+	}
+	if showDashboard {
+		if isTerminal(os.Stdout) {
+			dash = newDashboard(os.Stdout)
+			log.SetOutput(io.MultiWriter(runsimLogFile, dash))
+			go dash.run()
+		} else {
+			log.Printf("WARNING: stdout is not a terminal, dashboard disabled")
+		}
+	}
+
 	// set up worker pool
 	log.Printf("Allocating %d workers...", jobs)
 	wg := sync.WaitGroup{}
@@ -159,7 +372,7 @@ func main() {
 
 		go func(workerID int) {
 			defer wg.Done()
-			worker(workerID, seedQueue, results)
+			worker(ctx, workerID, seedQueue, results, unstarted)
 		}(workerID)
 	}
 
@@ -176,61 +389,239 @@ wait:
 		case <-waitCh:
 			break wait
 		case <-time.After(1 * time.Minute):
-			fmt.Println(".")
+			if dash == nil {
+				fmt.Println(".")
+			}
 		}
 	}
 
+	if dash != nil {
+		dash.Stop()
+		log.SetOutput(io.MultiWriter(os.Stdout, runsimLogFile))
+	}
+
 	// analyze results and collect the log file handles
 	close(results)
-	var okSeeds, failedSeeds, exports []string
+	if err := journal.Close(); err != nil {
+		log.Printf("ERROR: journal.Close: %v", err)
+	}
 	for seed := range results {
-		if seed.Failed {
-			failedSeeds = append(failedSeeds, seed.Stderr, seed.Stdout)
+		finishedSeeds = append(finishedSeeds, seed)
+	}
+
+	// seeds left in the queue when the run was interrupted
+	close(unstarted)
+	var notStarted []Seed
+	queued := make(map[string]bool)
+	for _, queue := range []chan Seed{unstarted, seedQueue} {
+		for seed := range queue {
+			// the runs of a determinism check are queued separately
+			if !queued[seed.name()] {
+				queued[seed.name()] = true
+				notStarted = append(notStarted, seed)
+			}
+		}
+	}
+
+	if reportFormat != "" {
+		if err := writeReport(reportFormat, reportFile, finishedSeeds); err != nil {
+			log.Printf("ERROR: writeReport: %v", err)
 		} else {
-			okSeeds = append(okSeeds, seed.Stderr, seed.Stdout)
+			log.Printf("Results report written to %s", reportFile)
+		}
+	}
+
+	failed := 0
+	for _, seed := range finishedSeeds {
+		if seed.Failed {
+			failed++
 		}
-		exports = append(exports, seed.ExportParams, seed.ExportState)
 	}
 
-	if notifyGithub || notifySlack {
-		publishResults(okSeeds, failedSeeds, exports)
+	summary := buildInterruptSummary(finishedSeeds, notStarted) +
+		buildFailureSummary(finishedSeeds) + buildResourceSummary(finishedSeeds)
+	log.Print(summary)
+	if ctx.Err() != nil {
+		log.Printf("Seed results were recorded to %s, rerun with -Resume to continue", journal.Name())
+	}
+	// interrupted runs would skew the pass rates, their seeds are recorded once resumed
+	if historyPath != "" && ctx.Err() == nil {
+		if err := recordHistory(historyPath, started, finishedSeeds); err != nil {
+			log.Printf("ERROR: recordHistory: %v", err)
+		} else {
+			log.Printf("Results recorded to the history in %s", historyPath)
+		}
+	}
+	if publishing() {
+		publishResults(failed > 0 || ctx.Err() != nil, summary)
+	} else if archives, err := archiver.Close(); err != nil {
+		log.Printf("ERROR: archiver.Close: %v", err)
+	} else if len(archives) > 0 {
+		log.Printf("Archives: %s", strings.Join(archives, " "))
 	}
 
-	if len(failedSeeds) > 0 {
+	if failed > 0 || ctx.Err() != nil {
 		os.Exit(1)
 	}
 
 	os.Exit(0)
 }
 
-func worker(id int, seeds <-chan Seed, results chan Seed) {
+// buildInterruptSummary lists the seeds that were stopped or never started because the run was interrupted.
+func buildInterruptSummary(results, notStarted []Seed) string {
+	var stopped []Seed
+	for _, seed := range results {
+		if seed.Interrupted {
+			stopped = append(stopped, seed)
+		}
+	}
+	if len(stopped) == 0 && len(notStarted) == 0 {
+		return ""
+	}
+
+	var summary strings.Builder
+	summary.WriteString("Run interrupted:\n")
+	if len(stopped) > 0 {
+		summary.WriteString(fmt.Sprintf("stopped (%d): %s\n", len(stopped), joinSeedNums(stopped)))
+	}
+	if len(notStarted) > 0 {
+		summary.WriteString(fmt.Sprintf("not started (%d): %s\n", len(notStarted), joinSeedNums(notStarted)))
+	}
+	return summary.String()
+}
+
+// worker runs the seeds it takes from seeds until there are none left or the
+// run is interrupted, a seed it takes after the interruption goes to unstarted.
+func worker(ctx context.Context, id int, seeds <-chan Seed, results, unstarted chan<- Seed) {
 	log.Printf("[W%d] Worker is up and running", id)
-	for seed := range seeds {
-		failed := false
-		if err := spawnProcess(id, seed); err != nil {
-			failed = true
-			log.Printf("[W%d] Seed %d: FAILED", id, seed.Num)
-			log.Printf("To reproduce run: %s",
-				buildCmdString(testname, blocks, period, genesis, seed.ExportState, seed.ExportParams, seed.Num))
+	for {
+		var seed Seed
+		select {
+		case <-ctx.Done():
+			log.Printf("[W%d] run interrupted, shutting down", id)
+			return
+		case s, ok := <-seeds:
+			if !ok {
+				log.Printf("[W%d] no seeds left, shutting down", id)
+				return
+			}
+			seed = s
+		}
+		if ctx.Err() != nil {
+			// the seed was received after cancellation, leave it pending in the journal
+			log.Printf("[W%d] run interrupted, shutting down", id)
+			unstarted <- seed
+			return
+		}
+
+		journal.record(seed, seedRunning)
+		var err error
+		if importStages != nil {
+			seed, err = runRoundTrip(ctx, id, seed)
+		} else {
+			seed, err = runSeed(ctx, id, seed)
+		}
+		if determinism != nil {
+			var done bool
+			if seed, err, done = determinism.add(seed, err); !done {
+				progress.idle(id)
+				continue
+			}
+		}
+		switch {
+		case seed.Interrupted:
+			log.Printf("[W%d] Seed %s: INTERRUPTED", id, seed.name())
+		case err != nil:
+			seed.Failed = true
+			switch {
+			case seed.Failure.Category == failureNonDeterminism:
+				log.Printf("[W%d] Seed %s: NON-DETERMINISTIC, %s", id, seed.name(), seed.Failure.Detail)
+			case seed.Failure.Stage != "":
+				log.Printf("[W%d] Seed %s: %s at stage %s (%s)", id, seed.name(), strings.ToUpper(seed.status()),
+					seed.Failure.Stage, seed.Failure.Category)
+			default:
+				log.Printf("[W%d] Seed %s: %s (%s)", id, seed.name(), strings.ToUpper(seed.status()), seed.Failure.Category)
+			}
+			log.Printf("To reproduce run: %s", seedCmdString(seed))
 
 			if exitOnFail {
 				log.Printf("\bERROR OUTPUT \n\n%s", err)
 				panic("halting simulations")
 			}
+		case seed.Flaky:
+			log.Printf("[W%d] Seed %s: FLAKY (passed on attempt %d/%d)", id, seed.name(), seed.Attempts, retries+1)
+		}
+		journal.record(seed, seed.status())
+		archiver.add(seed)
+		progress.seedFinished(id, seed)
+		results <- seed
+	}
+}
+
+// runSeed runs the simulation for a seed, retrying failed attempts up to -Retries times.
+// The logs of every attempt are kept, a seed that only passes on retry is marked as flaky.
+// A seed whose simulation is stopped because the run was interrupted is marked as such.
+func runSeed(ctx context.Context, workerID int, seed Seed) (Seed, error) {
+	stdout, stderr := seed.Stdout, seed.Stderr
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 {
+			backoff := retryBackoff * time.Duration(1<<uint(attempt-2))
+			log.Printf("[W%d] Seed %s: attempt %d/%d FAILED (%s), retrying in %s",
+				workerID, seed.name(), attempt-1, retries+1, seed.Failure.Category, backoff)
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				// the seed is rerun on resume, its failed attempts' logs are kept
+				seed.Interrupted = true
+				seed.Failure = nil
+				return seed, errors.New("run interrupted before retrying")
+			}
+
+			seed.PrevAttempts = append(seed.PrevAttempts, seed.Stdout, seed.Stderr)
+			seed.Stdout = buildRetryFileName(stdout, attempt)
+			seed.Stderr = buildRetryFileName(stderr, attempt)
+		}
+
+		if !memSched.admit(ctx, workerID, seed) {
+			seed.Interrupted = true
+			return seed, ctx.Err()
+		}
+		start := time.Now()
+		state, err := spawnProcess(ctx, workerID, seed)
+		seed.Duration = time.Since(start)
+		seed.Usage = getResourceUsage(state)
+		memSched.done(workerID, seed)
+		seed.ExitCode = exitCode(err)
+		seed.Attempts = attempt
+		log.Printf("[W%d] Seed %s: wall %s, %s", workerID, seed.name(), seed.Duration.Round(time.Millisecond), seed.Usage)
+		if err == nil {
+			seed.Flaky = attempt > 1
+			seed.Failure = nil
+			seed.TimedOut = false
+			return seed, nil
+		}
+		if ctx.Err() != nil {
+			seed.Interrupted = true
+			seed.Failure = nil
+			return seed, err
+		}
+
+		seed.Failure = classifyFailure(seed, err)
+		var timeoutErr *timeoutError
+		if seed.TimedOut = errors.As(err, &timeoutErr); seed.TimedOut {
+			seed.Failure.Category = failureTimeout
+			seed.Failure.Detail = fmt.Sprintf("killed by runsim after %s", timeoutErr.timeout)
 		}
-		results <- Seed{
-			Num:          seed.Num,
-			Stdout:       seed.Stdout,
-			Stderr:       seed.Stderr,
-			ExportParams: seed.ExportParams,
-			ExportState:  seed.ExportState,
-			Failed:       failed,
+		// build failures will not go away by retrying
+		if attempt > retries || seed.Failure.Category == failureBuild {
+			return seed, err
 		}
 	}
-	log.Printf("[W%d] no seeds left, shutting down", id)
 }
 
-func spawnProcess(workerID int, seed Seed) (err error) {
+// spawnProcess runs the simulation for a seed. When ctx is cancelled the simulation gets
+// SIGTERM, then SIGKILL if it's still running after the grace period.
+func spawnProcess(ctx context.Context, workerID int, seed Seed) (state *os.ProcessState, err error) {
 	stderrFile, err := os.Create(seed.Stderr)
 	if err != nil {
 		if notifyGithub || notifySlack {
@@ -247,30 +638,53 @@ func spawnProcess(workerID int, seed Seed) (err error) {
 		log.Fatal(err)
 	}
 
-	s := buildCmdString(testname, blocks, period, genesis, seed.ExportState, seed.ExportParams, seed.Num)
-	cmd := execCmd(s)
+	args := seedCmdArgs(seed)
+	cmd := execCmd(args)
+	if testBinaryDir != "" {
+		cmd.Dir = testBinaryDir
+	}
 	cmd.Stdout = stdoutFile
+	setProcessGroup(cmd)
 
 	var stderr io.ReadCloser
 	if !exitOnFail {
 		cmd.Stderr = stderrFile
 	} else {
 		if stderr, err = cmd.StderrPipe(); err != nil {
-			return err
+			return
 		}
 	}
 	sc := bufio.NewScanner(stderr)
 
 	if err = cmd.Start(); err != nil {
-		log.Printf("couldn't start %q", s)
-		return err
+		log.Printf("couldn't start %q", quoteArgs(args))
+		return
 	}
-	log.Printf("[W%d] Spawned simulation with pid %d [seed=%d stdout=%s stderr=%s]",
-		workerID, cmd.Process.Pid, seed.Num, seed.Stdout, seed.Stderr)
+	log.Printf("[W%d] Spawned simulation with pid %d [seed=%s stdout=%s stderr=%s]",
+		workerID, cmd.Process.Pid, seed.name(), seed.Stdout, seed.Stderr)
 	pushProcess(cmd.Process)
 	defer popProcess(cmd.Process)
+	progress.seedStarted(workerID, seed, cmd.Process.Pid)
+	memSched.setPid(workerID, cmd.Process.Pid)
+
+	var watchdog <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		watchdog = timer.C
+	}
 
-	if err = cmd.Wait(); err != nil {
+	waitCh := make(chan error, 1)
+	go func() { waitCh <- cmd.Wait() }()
+	select {
+	case err = <-waitCh:
+	case <-ctx.Done():
+		err = stopProcess(workerID, cmd.Process, waitCh)
+	case <-watchdog:
+		err = &timeoutError{timeout: timeout, err: quitProcess(workerID, cmd.Process, waitCh)}
+	}
+	reapGroup(cmd.Process)
+	if err != nil && dash == nil {
 		fmt.Printf("%s\n", err)
 	}
 
@@ -279,7 +693,7 @@ func spawnProcess(workerID int, seed Seed) (err error) {
 			fmt.Printf("stderr: %s\n", sc.Text())
 		}
 	}
-	return err
+	return cmd.ProcessState, err
 }
 
 func pushProcess(proc *os.Process) {
@@ -296,6 +710,49 @@ func popProcess(proc *os.Process) {
 	}
 }
 
+// stopProcess asks a simulation to terminate and kills it if it doesn't within the grace period.
+func stopProcess(workerID int, proc *os.Process, waitCh <-chan error) error {
+	log.Printf("[W%d] Sending %s to PID %d", workerID, syscall.SIGTERM, proc.Pid)
+	checkSignal(proc, syscall.SIGTERM)
+	select {
+	case err := <-waitCh:
+		return err
+	case <-time.After(gracePeriod):
+		log.Printf("[W%d] PID %d still running after %s, sending %s", workerID, proc.Pid, gracePeriod, syscall.SIGKILL)
+		checkSignal(proc, syscall.SIGKILL)
+		return <-waitCh
+	}
+}
+
+// quitProcess makes a simulation that hit the timeout dump its goroutines
+// with SIGQUIT, then kills it if it's still running.
+func quitProcess(workerID int, proc *os.Process, waitCh <-chan error) error {
+	log.Printf("[W%d] PID %d timed out after %s, sending %s", workerID, proc.Pid, timeout, syscall.SIGQUIT)
+	checkSignal(proc, syscall.SIGQUIT)
+	select {
+	case err := <-waitCh:
+		return err
+	case <-time.After(quitTimeout):
+		log.Printf("[W%d] PID %d still running after %s, sending %s", workerID, proc.Pid, quitTimeout, syscall.SIGKILL)
+		checkSignal(proc, syscall.SIGKILL)
+		return <-waitCh
+	}
+}
+
+// timeoutError is returned for simulations killed by the runsim watchdog.
+type timeoutError struct {
+	timeout time.Duration
+	err     error
+}
+
+func (e *timeoutError) Error() string {
+	return fmt.Sprintf("timed out after %s: %v", e.timeout, e.err)
+}
+
+func (e *timeoutError) Unwrap() error {
+	return e.err
+}
+
 func killAllProcs() {
 	mutex.Lock()
 	defer mutex.Unlock()
@@ -305,37 +762,42 @@ func killAllProcs() {
 	}
 }
 
+// checkSignal sends a signal to the simulation's whole process group.
 func checkSignal(proc *os.Process, signal syscall.Signal) {
-	if err := proc.Signal(signal); err != nil {
-		log.Printf("Failed to send %s to PID %d", signal, proc.Pid)
+	if err := signalGroup(proc, signal); err != nil {
+		log.Printf("Failed to send %s to process group %d", signal, proc.Pid)
 	}
 }
 
 func buildCmdString(testName, blocks, period, genesis, exportStatePath, exportParamsPath string, seed int) string {
-	return fmt.Sprintf("go test %s -run %s -Enabled=true -NumBlocks=%s -Genesis=%s -Verbose=true "+
-		"-Commit=true -Seed=%d -Period=%s -ExportParamsPath %s -ExportStatePath %s -v -timeout %s",
-		pkgName, testName, blocks, genesis, seed, period, exportParamsPath, exportStatePath, timeout)
+	return quoteArgs(buildCmdArgs(testName, blocks, period, genesis, exportStatePath, exportParamsPath, seed))
 }
 
-func execCmd(cmdStr string) *exec.Cmd {
-	cmdSlice := strings.Split(cmdStr, " ")
-	return exec.Command(cmdSlice[0], cmdSlice[1:]...)
+// seedCmdArgs returns the command simulating seed with the genesis and blocks
+// of its matrix cell, which runs the test of its stage and imports the
+// previous stage's exports in import/export round trips.
+func seedCmdArgs(seed Seed) []string {
+	genesis, blocks := seedCell(seed)
+	return expandCmd(cmdParams{
+		TestName:         stageTest(seed.Stage),
+		Blocks:           blocks,
+		Period:           period,
+		Genesis:          genesis,
+		Seed:             seed.Num,
+		ExportStatePath:  seed.ExportState,
+		ExportParamsPath: seed.ExportParams,
+		ImportStatePath:  seed.ImportState,
+		ImportParamsPath: seed.ImportParams,
+	})
 }
 
-func buildSeedList(seeds string) ([]int, error) {
-	strSeedsLst := strings.Split(seeds, ",")
-	if len(strSeedsLst) == 0 {
-		return nil, fmt.Errorf("seeds was empty")
-	}
-	intSeeds := make([]int, len(strSeedsLst))
-	for i, seedStr := range strSeedsLst {
-		intSeed, err := strconv.Atoi(strings.TrimSpace(seedStr))
-		if err != nil {
-			return nil, fmt.Errorf("cannot convert seed to integer: %v", err)
-		}
-		intSeeds[i] = intSeed
-	}
-	return intSeeds, nil
+func seedCmdString(seed Seed) string {
+	return quoteArgs(seedCmdArgs(seed))
+}
+
+func buildRetryFileName(fileName string, attempt int) string {
+	ext := filepath.Ext(fileName)
+	return fmt.Sprintf("%s-retry-%d%s", strings.TrimSuffix(fileName, ext), attempt-1, ext)
 }
 
 func buildLogFileName(seed int) string {
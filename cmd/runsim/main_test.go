@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkerInterrupted(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	seeds := make(chan Seed, 3)
+	for num := 1; num <= 3; num++ {
+		seeds <- Seed{Num: num}
+	}
+	close(seeds)
+	results, unstarted := make(chan Seed, 3), make(chan Seed, 3)
+
+	// the worker may take a seed from the queue before it sees the cancellation,
+	// that seed is not started either
+	for i := 0; i < 3; i++ {
+		worker(ctx, 0, seeds, results, unstarted)
+	}
+	close(results)
+	close(unstarted)
+	require.Empty(t, results)
+	var notStarted []Seed
+	for _, queue := range []chan Seed{unstarted, seeds} {
+		for seed := range queue {
+			notStarted = append(notStarted, seed)
+		}
+	}
+	require.ElementsMatch(t, []Seed{{Num: 1}, {Num: 2}, {Num: 3}}, notStarted)
+}
+
+func TestBuildInterruptSummary(t *testing.T) {
+	require.Equal(t, "", buildInterruptSummary([]Seed{{Num: 1}}, nil))
+	require.Equal(t, "Run interrupted:\nstopped (1): 2\nnot started (2): 3, 4\n",
+		buildInterruptSummary([]Seed{{Num: 1}, {Num: 2, Interrupted: true}}, []Seed{{Num: 3}, {Num: 4}}))
+}
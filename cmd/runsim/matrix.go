@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// label of the cells without a genesis file, whose genesis the simulation generates
+const randomGenesis = "random"
+
+// matrixCell is a combination of genesis file and block count every seed of a
+// matrix run is simulated with.
+type matrixCell struct {
+	Label   string `json:"label"`
+	Genesis string `json:"genesis,omitempty"`
+	Blocks  string `json:"blocks"`
+}
+
+// the cells of a matrix run, nil if a single genesis and block count are given
+var matrix []matrixCell
+
+// buildMatrix returns the cells of genesisList × blocksList. The genesis list
+// holds comma-separated files and directories, whose JSON files are all used;
+// the block list comma-separated block counts. A single cell is no matrix, the
+// genesis file it expands to is returned instead, e.g. the only one of a directory.
+func buildMatrix(genesisList, blocksList string) ([]matrixCell, string, error) {
+	geneses, err := expandGenesisList(genesisList)
+	if err != nil {
+		return nil, "", err
+	}
+	var counts []string
+	for _, count := range strings.Split(blocksList, ",") {
+		count = strings.TrimSpace(count)
+		if n, err := strconv.Atoi(count); err != nil || n < 0 {
+			return nil, "", fmt.Errorf("invalid block count %q", count)
+		}
+		counts = append(counts, count)
+	}
+	if len(geneses) == 1 && len(counts) == 1 {
+		return nil, geneses[0], nil
+	}
+
+	names := genesisNames(geneses)
+	var cells []matrixCell
+	for i, genesis := range geneses {
+		for _, count := range counts {
+			cells = append(cells, matrixCell{Label: names[i] + "@" + count, Genesis: genesis, Blocks: count})
+		}
+	}
+	return cells, genesisList, nil
+}
+
+// expandGenesisList splits a comma-separated list of genesis files and
+// directories into files, an empty list standing for a random genesis.
+func expandGenesisList(list string) (geneses []string, err error) {
+	if strings.TrimSpace(list) == "" {
+		return []string{""}, nil
+	}
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		info, err := os.Stat(item)
+		if err != nil || !info.IsDir() {
+			// a missing genesis file fails the simulations as it always did
+			geneses = append(geneses, item)
+			continue
+		}
+		files, err := ioutil.ReadDir(item)
+		if err != nil {
+			return nil, err
+		}
+		// the simulations may not run in the current directory
+		dir, err := filepath.Abs(item)
+		if err != nil {
+			return nil, err
+		}
+		var found bool
+		for _, file := range files {
+			if !file.IsDir() && filepath.Ext(file.Name()) == ".json" {
+				geneses = append(geneses, filepath.Join(dir, file.Name()))
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("no genesis files in %s", item)
+		}
+	}
+	return
+}
+
+// genesisNames names the genesis files of a matrix after their base names,
+// numbered if the same name appears twice.
+func genesisNames(geneses []string) []string {
+	names := make([]string, len(geneses))
+	seen := make(map[string]int)
+	for i, genesis := range geneses {
+		name := randomGenesis
+		if genesis != "" {
+			name = strings.TrimSuffix(filepath.Base(genesis), filepath.Ext(genesis))
+		}
+		if seen[name]++; seen[name] > 1 {
+			name = fmt.Sprintf("%s-%d", name, seen[name])
+		}
+		names[i] = name
+	}
+	return names
+}
+
+// seedCell returns the genesis file and block count a seed is simulated with.
+func seedCell(seed Seed) (string, string) {
+	for _, cell := range matrix {
+		if cell.Label == seed.Cell {
+			return cell.Genesis, cell.Blocks
+		}
+	}
+	return genesis, blocks
+}
+
+// checkCells checks that resumed seeds run in the cells of this run's matrix.
+func checkCells(seeds []Seed) error {
+	labels := make(map[string]bool)
+	for _, cell := range matrix {
+		labels[cell.Label] = true
+	}
+	var unknown []string
+	for _, seed := range seeds {
+		if matrix == nil && seed.Cell == "" || labels[seed.Cell] {
+			continue
+		}
+		label := seed.Cell
+		if label == "" {
+			label = "(none)"
+		}
+		if !contains(unknown, label) {
+			unknown = append(unknown, label)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("the resumed seeds ran in matrix cells %s, rerun with the same -Genesis and blocks",
+			strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// matrixSeeds returns the seeds to run, every seed once per matrix cell.
+func matrixSeeds(nums []int) []Seed {
+	var seeds []Seed
+	for _, num := range nums {
+		if matrix == nil {
+			seeds = append(seeds, Seed{Num: num})
+			continue
+		}
+		for _, cell := range matrix {
+			seeds = append(seeds, Seed{Num: num, Cell: cell.Label})
+		}
+	}
+	return seeds
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildMatrix(t *testing.T) {
+	dir, err := ioutil.TempDir("", "runsim-matrix")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{"mainnet.json", "synthetic.json", "notes.txt"} {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte("{}"), 0644))
+	}
+
+	cells, genesis, err := buildMatrix("", "100")
+	require.NoError(t, err)
+	require.Nil(t, cells)
+	require.Equal(t, "", genesis)
+
+	single := filepath.Join(dir, "single")
+	require.NoError(t, os.Mkdir(single, 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(single, "mainnet.json"), []byte("{}"), 0644))
+	cells, genesis, err = buildMatrix(single+"/", "100")
+	require.NoError(t, err)
+	require.Nil(t, cells)
+	require.Equal(t, filepath.Join(single, "mainnet.json"), genesis)
+
+	cells, _, err = buildMatrix("", "100,500")
+	require.NoError(t, err)
+	require.Equal(t, []matrixCell{{Label: "random@100", Blocks: "100"}, {Label: "random@500", Blocks: "500"}}, cells)
+
+	cells, _, err = buildMatrix(dir+",other/mainnet.json", "100")
+	require.NoError(t, err)
+	require.Equal(t, []matrixCell{
+		{Label: "mainnet@100", Genesis: filepath.Join(dir, "mainnet.json"), Blocks: "100"},
+		{Label: "synthetic@100", Genesis: filepath.Join(dir, "synthetic.json"), Blocks: "100"},
+		{Label: "mainnet-2@100", Genesis: "other/mainnet.json", Blocks: "100"},
+	}, cells)
+
+	_, _, err = buildMatrix("", "100,ten")
+	require.Error(t, err)
+	empty := filepath.Join(dir, "empty")
+	require.NoError(t, os.Mkdir(empty, 0755))
+	_, _, err = buildMatrix(empty, "100")
+	require.Error(t, err)
+}
+
+func TestResumeMatrixSeeds(t *testing.T) {
+	dir, err := ioutil.TempDir("", "runsim-matrix")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	fileName := filepath.Join(dir, "journal")
+	require.NoError(t, ioutil.WriteFile(fileName, []byte(`{"seed":1,"cell":"mainnet@100","status":"pending"}
+{"seed":1,"cell":"mainnet@500","status":"pending"}
+{"seed":1,"cell":"mainnet@100","status":"passed"}
+`), 0644))
+	pending, finished, err := resumeSeeds(fileName)
+	require.NoError(t, err)
+	require.Equal(t, []Seed{{Num: 1, Cell: "mainnet@500"}}, pending)
+	require.Len(t, finished, 1)
+	require.Equal(t, "1 [mainnet@100]", finished[0].name())
+
+	matrix = []matrixCell{{Label: "mainnet@100"}, {Label: "mainnet@500"}}
+	defer func() { matrix = nil }()
+	require.NoError(t, checkCells(append(pending, finished...)))
+	require.Error(t, checkCells([]Seed{{Num: 1}}))
+	matrix = nil
+	require.Error(t, checkCells(pending))
+}
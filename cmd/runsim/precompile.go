@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Command template used with -Precompile, the test binary takes the go test
+// flags with the "test." prefix.
+const precompiledCmdTemplate = `{{.TestBinary}} -test.run {{.TestName}} -test.v -test.timeout {{.Timeout}} ` +
+	`-Enabled=true -NumBlocks={{.Blocks}} ` + genesisArg + ` -Verbose=true -Commit=true -Seed={{.Seed}} ` +
+	`-Period={{.Period}} -ExportParamsPath {{.ExportParamsPath}} -ExportStatePath {{.ExportStatePath}}`
+
+var (
+	// path of the precompiled simulation test binary and the directory it runs in
+	testBinary, testBinaryDir string
+
+	// set when the simulation test binary could not be built
+	buildFailure *failureInfo
+)
+
+// precompileTestBinary builds the simulation test binary once with go test -c, so that the
+// workers don't compete to compile and link the same package for every seed.
+// The build output is kept in buildLog, buildFailure is set if it fails.
+func precompileTestBinary(dir, buildLog string) (err error) {
+	buildFailure = nil
+	binary := filepath.Join(dir, "sim.test")
+	log.Printf("Building simulation test binary %s...", binary)
+
+	out, err := exec.Command("go", "test", "-c", "-o", binary, pkgName).CombinedOutput()
+	if werr := ioutil.WriteFile(buildLog, out, 0666); werr != nil {
+		log.Printf("ERROR: ioutil.WriteFile: %v", werr)
+	}
+	if err != nil {
+		buildFailure = &failureInfo{Category: failureBuild, Detail: firstBuildError(string(out))}
+		return fmt.Errorf("go test -c %s: %v", pkgName, err)
+	}
+
+	// go test runs test binaries from the package directory, do the same so that relative paths keep working
+	out, err = exec.Command("go", "list", "-f", "{{.Dir}}", pkgName).Output()
+	if err != nil {
+		err = fmt.Errorf("go list %s: %v", pkgName, err)
+		buildFailure = &failureInfo{Category: failureBuild, Detail: err.Error()}
+		return
+	}
+
+	testBinary, testBinaryDir = binary, strings.TrimSpace(string(out))
+	return
+}
+
+// firstBuildError returns the first compiler error from the go build output.
+func firstBuildError(output string) string {
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			return line
+		}
+	}
+	return ""
+}
+
+// reportBuildFailure publishes the build log instead of the seed logs, so the
+// run shows up as a single build failure rather than a failure of every seed.
+func reportBuildFailure(buildLog string) {
+	summary := fmt.Sprintf("Failures by category:\n%s: the simulation test binary could not be built\n", failureBuild)
+	if buildFailure.Detail != "" {
+		summary += fmt.Sprintf("    %s\n", buildFailure.Detail)
+	}
+	log.Print(summary)
+
+	if reportFormat != "" {
+		if err := writeReport(reportFormat, reportFile, nil); err != nil {
+			log.Printf("ERROR: writeReport: %v", err)
+		}
+	}
+	if publishing() {
+		archiver.addOther(failedArchive, buildLog)
+		publishResults(true, summary)
+	}
+	os.Exit(1)
+}
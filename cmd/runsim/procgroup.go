@@ -0,0 +1,32 @@
+package main
+
+import (
+	"log"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// Every simulation runs in its own process group, so that signals reach the
+// test binary spawned by go test as well and none of them is left behind as
+// an orphan when a seed is killed.
+
+func setProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+}
+
+// signalGroup sends a signal to the process group led by proc.
+func signalGroup(proc *os.Process, signal syscall.Signal) error {
+	return syscall.Kill(-proc.Pid, signal)
+}
+
+// reapGroup kills whatever is left of the process group once its leader has
+// exited, the group is usually gone by then.
+func reapGroup(proc *os.Process) {
+	if err := signalGroup(proc, syscall.SIGKILL); err == nil {
+		log.Printf("Killed processes left behind in group %d", proc.Pid)
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// groupMembers lists the live processes of a process group.
+func groupMembers(t *testing.T, pgid int) []int {
+	stats, err := filepath.Glob("/proc/[0-9]*/stat")
+	require.NoError(t, err)
+	var pids []int
+	for _, stat := range stats {
+		content, err := ioutil.ReadFile(stat)
+		if err != nil {
+			continue
+		}
+		// fields after the command: state(3) ppid(4) pgrp(5)
+		s := string(content)
+		fields := strings.Fields(s[strings.LastIndexByte(s, ')')+1:])
+		if fields[0] == "Z" || fields[2] != strconv.Itoa(pgid) {
+			continue
+		}
+		pid, err := strconv.Atoi(strings.TrimSpace(s[:strings.IndexByte(s, '(')]))
+		require.NoError(t, err)
+		pids = append(pids, pid)
+	}
+	return pids
+}
+
+func TestKillAllProcsKillsProcessTree(t *testing.T) {
+	if _, err := ioutil.ReadFile("/proc/self/stat"); err != nil {
+		t.Skip("/proc is not available")
+	}
+	procs, mutex = map[int]*os.Process{}, &sync.Mutex{}
+
+	// a shell standing in for go test, with a grandchild standing in for the test binary
+	cmd := exec.Command("sh", "-c", "sh -c 'trap \"\" TERM; sleep 60' & sleep 60 & wait")
+	setProcessGroup(cmd)
+	require.NoError(t, cmd.Start())
+	pushProcess(cmd.Process)
+	defer popProcess(cmd.Process)
+
+	require.Eventually(t, func() bool { return len(groupMembers(t, cmd.Process.Pid)) >= 4 },
+		5*time.Second, 10*time.Millisecond)
+
+	killAllProcs()
+	require.Error(t, cmd.Wait())
+	require.Eventually(t, func() bool { return len(groupMembers(t, cmd.Process.Pid)) == 0 },
+		5*time.Second, 10*time.Millisecond)
+}
+
+// useSimCmd makes the simulations run script with sh, the returned function restores the command.
+func useSimCmd(t *testing.T, script string) func() {
+	argv, err := parseCmdTemplate(`sh -c '` + script + `'`)
+	require.NoError(t, err)
+	savedTemplate, savedTimeout, savedQuit := cmdTemplate, timeout, quitTimeout
+	cmdTemplate = argv
+	procs, mutex = map[int]*os.Process{}, &sync.Mutex{}
+	progress = newProgressTracker(1, 0)
+	return func() { cmdTemplate, timeout, quitTimeout = savedTemplate, savedTimeout, savedQuit }
+}
+
+func newTestSeed(t *testing.T, dir string) Seed {
+	return Seed{Num: 1, Stdout: filepath.Join(dir, "stdout"), Stderr: filepath.Join(dir, "stderr")}
+}
+
+func TestWatchdogQuitsThenKills(t *testing.T) {
+	dir, err := ioutil.TempDir("", "runsim")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	// a simulation that ignores SIGQUIT, it notes when it got one
+	defer useSimCmd(t, `trap "echo quit" QUIT; while true; do sleep 0.05; done`)()
+	timeout, quitTimeout = 100*time.Millisecond, 300*time.Millisecond
+	seed := newTestSeed(t, dir)
+
+	start := time.Now()
+	state, err := spawnProcess(context.Background(), 0, seed)
+	var timeoutErr *timeoutError
+	require.True(t, errors.As(err, &timeoutErr), "%v", err)
+	require.True(t, time.Since(start) >= timeout+quitTimeout)
+	require.Equal(t, syscall.SIGKILL, state.Sys().(syscall.WaitStatus).Signal())
+
+	stdout, err := ioutil.ReadFile(seed.Stdout)
+	require.NoError(t, err)
+	require.Contains(t, string(stdout), "quit")
+}
+
+func TestRunSeedPassesAfterTimeout(t *testing.T) {
+	dir, err := ioutil.TempDir("", "runsim")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	// the first attempt hangs, the second passes
+	marker := filepath.Join(dir, "attempted")
+	defer useSimCmd(t, `test -e `+marker+` && exit 0; touch `+marker+`; sleep 60`)()
+	timeout, quitTimeout = 100*time.Millisecond, time.Second
+	savedRetries, savedBackoff := retries, retryBackoff
+	defer func() { retries, retryBackoff = savedRetries, savedBackoff }()
+	retries, retryBackoff = 1, 0
+
+	result, err := runSeed(context.Background(), 0, newTestSeed(t, dir))
+	require.NoError(t, err)
+	require.Equal(t, 2, result.Attempts)
+	require.True(t, result.Flaky)
+	require.False(t, result.TimedOut)
+	require.Nil(t, result.Failure)
+	require.Equal(t, seedFlaky, result.status())
+	require.Len(t, result.PrevAttempts, 2)
+}
+
+func TestRunSeedInterruptedBeforeRetry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "runsim")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	defer useSimCmd(t, `exit 1`)()
+	savedRetries, savedBackoff := retries, retryBackoff
+	defer func() { retries, retryBackoff = savedRetries, savedBackoff }()
+	retries, retryBackoff = 1, time.Hour
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(200*time.Millisecond, cancel)
+	result, err := runSeed(ctx, 0, newTestSeed(t, dir))
+	require.Error(t, err)
+	require.True(t, result.Interrupted)
+	require.Nil(t, result.Failure)
+	require.Equal(t, seedInterrupted, result.status())
+}
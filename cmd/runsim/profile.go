@@ -0,0 +1,184 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+)
+
+// profile keys of the command line arguments
+const (
+	profileTest   = "test"
+	profileBlocks = "blocks"
+	profilePeriod = "period"
+)
+
+// profileFlags maps the keys of a run profile to the flags they set. Keys of
+// nested sections are joined with dots, e.g. notify.slack.
+var profileFlags = map[string]string{
+	"package":               "SimAppPkg",
+	"seeds":                 "Seeds",
+	"seed_file":             "SeedFile",
+	"random_seeds":          "RandomSeeds",
+	"master_seed":           "MasterSeed",
+	"genesis":               "Genesis",
+	"jobs":                  "Jobs",
+	"timeout":               "Timeout",
+	"grace_period":          "GracePeriod",
+	"retries":               "Retries",
+	"retry_backoff":         "RetryBackoff",
+	"exit_on_fail":          "ExitOnFail",
+	"precompile":            "Precompile",
+	"cmd_template":          "CmdTemplate",
+	"cmd_template_file":     "CmdTemplateFile",
+	"determinism":           "Determinism",
+	"import_export":         "ImportExport",
+	"import_tests":          "ImportTests",
+	"mem_schedule":          "MemSchedule",
+	"mem_limit":             "MemLimit",
+	"seed_mem":              "SeedMem",
+	"report":                "Report",
+	"report_file":           "ReportFile",
+	"history_db":            "HistoryDB",
+	"notify.github":         "Github",
+	"notify.slack":          "Slack",
+	"artifacts.store":       "ArtifactStore",
+	"artifacts.dir":         "ArtifactDir",
+	"artifacts.bucket":      "LogBucket",
+	"artifacts.s3_endpoint": "S3Endpoint",
+	"artifacts.prefix":      "LogObjPrefix",
+	"artifacts.format":      "ArchiveFormat",
+	"artifacts.presign":     "PresignLinks",
+}
+
+// applyProfile loads a run profile, given as file or file:name for a named
+// profile of a file holding several, and sets the flags and arguments it
+// specifies that weren't given on the command line, as parsed by fs. It
+// returns the blocks, period and test name arguments.
+func applyProfile(fs *flag.FlagSet, profile string, args []string) ([]string, error) {
+	values, err := loadProfile(profile)
+	if err != nil {
+		return nil, err
+	}
+
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		name, ok := profileFlags[key]
+		if !ok || set[name] {
+			continue
+		}
+		if err := fs.Set(name, values[key]); err != nil {
+			return nil, fmt.Errorf("%s: %s: %v", profile, key, err)
+		}
+	}
+
+	if len(args) > 0 {
+		return args, nil
+	}
+	args = []string{values[profileBlocks], values[profilePeriod], values[profileTest]}
+	for i, key := range []string{profileBlocks, profilePeriod, profileTest} {
+		if args[i] == "" {
+			return nil, fmt.Errorf("%s: %s is required unless given on the command line", profile, key)
+		}
+	}
+	return args, nil
+}
+
+// loadProfile reads a YAML or TOML run profile into flag values by key.
+func loadProfile(profile string) (map[string]string, error) {
+	fileName, name := profile, ""
+	if _, err := os.Stat(profile); err != nil {
+		if i := strings.LastIndex(profile, ":"); i > 0 {
+			fileName, name = profile[:i], profile[i+1:]
+		}
+	}
+	content, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc map[string]interface{}
+	switch filepath.Ext(fileName) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(content, &doc)
+	case ".toml":
+		err = toml.Unmarshal(content, &doc)
+	default:
+		return nil, fmt.Errorf("%s: unknown profile format, expected .yaml, .yml or .toml", fileName)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", fileName, err)
+	}
+
+	if name != "" {
+		named, ok := doc[name]
+		if !ok {
+			return nil, fmt.Errorf("%s: no profile named %q", fileName, name)
+		}
+		if doc, ok = stringMap(named); !ok {
+			return nil, fmt.Errorf("%s: profile %q is not a table", fileName, name)
+		}
+	}
+
+	values := make(map[string]string)
+	if err := flattenProfile(doc, "", values); err != nil {
+		return nil, fmt.Errorf("%s: %v", profile, err)
+	}
+	return values, nil
+}
+
+// flattenProfile turns the values of a profile into flag values, keyed by
+// their dotted path. Lists are joined with commas.
+func flattenProfile(doc map[string]interface{}, prefix string, values map[string]string) error {
+	for key, value := range doc {
+		key = prefix + key
+		if section, ok := stringMap(value); ok {
+			if err := flattenProfile(section, key+".", values); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, ok := profileFlags[key]; !ok && key != profileTest && key != profileBlocks && key != profilePeriod {
+			return fmt.Errorf("unknown key %s", key)
+		}
+		switch value := value.(type) {
+		case []interface{}:
+			items := make([]string, len(value))
+			for i, item := range value {
+				items[i] = fmt.Sprint(item)
+			}
+			values[key] = strings.Join(items, ",")
+		default:
+			values[key] = fmt.Sprint(value)
+		}
+	}
+	return nil
+}
+
+// stringMap returns a YAML or TOML table as a map with string keys.
+func stringMap(value interface{}) (map[string]interface{}, bool) {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		return value, true
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(value))
+		for key, v := range value {
+			m[fmt.Sprint(key)] = v
+		}
+		return m, true
+	}
+	return nil, false
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadProfile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "runsim-profile")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	yamlFile := filepath.Join(dir, "profiles.yaml")
+	require.NoError(t, ioutil.WriteFile(yamlFile, []byte(`
+nightly:
+  test: TestFullAppSimulation
+  blocks: [400, 800]
+  period: 50
+  seeds: 1-100
+  timeout: 6h
+  notify:
+    slack: true
+  artifacts:
+    store: local
+    dir: /srv/sims
+pr-quick:
+  test: TestFullAppSimulation
+  blocks: 50
+  period: 10
+`), 0644))
+	values, err := loadProfile(yamlFile + ":nightly")
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		"test": "TestFullAppSimulation", "blocks": "400,800", "period": "50", "seeds": "1-100", "timeout": "6h",
+		"notify.slack": "true", "artifacts.store": "local", "artifacts.dir": "/srv/sims",
+	}, values)
+
+	_, err = loadProfile(yamlFile + ":weekly")
+	require.Error(t, err)
+
+	tomlFile := filepath.Join(dir, "quick.toml")
+	require.NoError(t, ioutil.WriteFile(tomlFile, []byte(`
+test = "TestFullAppSimulation"
+blocks = 50
+period = 10
+retries = 2
+seeds = [1, 2, 10]
+
+[artifacts]
+format = "tar.zst"
+`), 0644))
+	values, err = loadProfile(tomlFile)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		"test": "TestFullAppSimulation", "blocks": "50", "period": "10", "retries": "2", "seeds": "1,2,10",
+		"artifacts.format": "tar.zst",
+	}, values)
+
+	require.NoError(t, ioutil.WriteFile(tomlFile, []byte("sedes = \"1\"\n"), 0644))
+	_, err = loadProfile(tomlFile)
+	require.EqualError(t, err, tomlFile+": unknown key sedes")
+}
+
+func TestApplyProfile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "runsim-profile")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	fs := flag.NewFlagSet("runsim", flag.ContinueOnError)
+	retries := fs.Int("Retries", 0, "")
+	timeout := fs.Duration("Timeout", 0, "")
+
+	fileName := filepath.Join(dir, "nightly.yml")
+	require.NoError(t, ioutil.WriteFile(fileName, []byte("test: TestX\nblocks: 100\nperiod: 5\nretries: 3\ntimeout: 2h\n"), 0644))
+	require.NoError(t, fs.Parse([]string{"-Retries", "1"}))
+
+	args, err := applyProfile(fs, fileName, nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{"100", "5", "TestX"}, args)
+	require.Equal(t, 1, *retries)
+	require.Equal(t, 2*time.Hour, *timeout)
+
+	args, err = applyProfile(fs, fileName, []string{"10", "1", "TestY"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"10", "1", "TestY"}, args)
+
+	require.NoError(t, ioutil.WriteFile(fileName, []byte("test: TestX\nperiod: 5\n"), 0644))
+	_, err = applyProfile(fs, fileName, nil)
+	require.Error(t, err)
+}
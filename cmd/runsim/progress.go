@@ -0,0 +1,104 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// progress tracks what every worker is running and the results collected so
+// far, for the dashboard and the status server.
+var progress *progressTracker
+
+type workerState struct {
+	Seed    Seed
+	Pid     int
+	Started time.Time
+	Busy    bool
+}
+
+type progressTracker struct {
+	mtx     sync.Mutex
+	started time.Time
+	total   int
+	workers []workerState
+	results []Seed
+}
+
+// progressSnapshot is a copy of the tracker's state that can be read without locking.
+type progressSnapshot struct {
+	Started time.Time
+	Total   int
+	Workers []workerState
+	Results []Seed
+
+	Passed, Failed, Flaky, Interrupted int
+	BusyTime                           time.Duration
+}
+
+func newProgressTracker(workers, total int) *progressTracker {
+	return &progressTracker{
+		started: time.Now(),
+		total:   total,
+		workers: make([]workerState, workers),
+	}
+}
+
+func (p *progressTracker) seedStarted(workerID int, seed Seed, pid int) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	p.workers[workerID] = workerState{Seed: seed, Pid: pid, Started: time.Now(), Busy: true}
+}
+
+// idle marks the worker as idle without recording a result, e.g. after one of the runs of a determinism check.
+func (p *progressTracker) idle(workerID int) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	p.workers[workerID].Busy = false
+}
+
+func (p *progressTracker) seedFinished(workerID int, seed Seed) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	p.workers[workerID].Busy = false
+	p.results = append(p.results, seed)
+}
+
+func (p *progressTracker) snapshot() progressSnapshot {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	snap := progressSnapshot{
+		Started: p.started,
+		Total:   p.total,
+		Workers: append([]workerState(nil), p.workers...),
+		Results: append([]Seed(nil), p.results...),
+	}
+	for _, seed := range p.results {
+		snap.BusyTime += seed.Duration
+		switch seed.status() {
+		case seedFailed, seedTimedOut:
+			snap.Failed++
+		case seedFlaky:
+			snap.Flaky++
+		case seedInterrupted:
+			snap.Interrupted++
+		default:
+			snap.Passed++
+		}
+	}
+	return snap
+}
+
+func (snap progressSnapshot) done() int {
+	return len(snap.Results)
+}
+
+// eta assumes the remaining seeds take as long as the completed ones did on average.
+func (snap progressSnapshot) eta() string {
+	if snap.done() == 0 || len(snap.Workers) == 0 {
+		return "unknown"
+	}
+	remaining := snap.Total - snap.done()
+	avg := snap.BusyTime / time.Duration(snap.done())
+	return (avg * time.Duration(remaining) / time.Duration(len(snap.Workers))).Round(time.Second).String()
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// supported report formats
+const (
+	reportJSON  = "json"
+	reportJUnit = "junit"
+)
+
+type runReport struct {
+	TestName string       `json:"test_name"`
+	Package  string       `json:"package"`
+	Blocks   string       `json:"blocks"`
+	Period   string       `json:"period"`
+	Genesis  string       `json:"genesis,omitempty"`
+	Matrix   []matrixCell `json:"matrix,omitempty"`
+	Profile  string       `json:"profile,omitempty"`
+	HostId   string       `json:"host_id,omitempty"`
+	Seeds    []seedReport `json:"seeds"`
+
+	// master seed of the randomly generated seeds
+	MasterSeed int64 `json:"master_seed,omitempty"`
+
+	// set instead of per-seed results when the simulation test binary could not be built
+	BuildFailure *failureInfo `json:"build_failure,omitempty"`
+}
+
+type seedReport struct {
+	Seed         int          `json:"seed"`
+	Cell         string       `json:"cell,omitempty"`
+	Status       string       `json:"status"`
+	Attempts     int          `json:"attempts"`
+	ExitCode     int          `json:"exit_code"`
+	WallTime     float64      `json:"wall_time_seconds"`
+	UserCPU      float64      `json:"user_cpu_seconds"`
+	SysCPU       float64      `json:"sys_cpu_seconds"`
+	MaxRSS       int64        `json:"max_rss_bytes"`
+	Reproduce    string       `json:"reproduce"`
+	Stdout       string       `json:"stdout"`
+	Stderr       string       `json:"stderr"`
+	ExportParams string       `json:"export_params"`
+	ExportState  string       `json:"export_state"`
+	PrevAttempts []string     `json:"prev_attempts,omitempty"`
+	Runs         []string     `json:"runs,omitempty"`
+	Stages       []string     `json:"stages,omitempty"`
+	Failure      *failureInfo `json:"failure,omitempty"`
+}
+
+func (seed seedReport) name() string {
+	return Seed{Num: seed.Seed, Cell: seed.Cell}.name()
+}
+
+func validateReportFormat(format string) error {
+	switch format {
+	case "", reportJSON, reportJUnit:
+		return nil
+	}
+	return fmt.Errorf("unknown report format %q, expected %q or %q", format, reportJSON, reportJUnit)
+}
+
+func buildReport(results []Seed) runReport {
+	report := runReport{
+		TestName: testname,
+		Package:  pkgName,
+		Blocks:   blocks,
+		Period:   period,
+		Genesis:  genesis,
+		Matrix:   matrix,
+		Profile:  profilePath,
+		HostId:   hostId,
+		Seeds:    make([]seedReport, len(results)),
+
+		BuildFailure: buildFailure,
+	}
+	if randomSeeds > 0 {
+		report.MasterSeed = masterSeed
+	}
+	for i, seed := range results {
+		report.Seeds[i] = seedReport{
+			Seed:         seed.Num,
+			Cell:         seed.Cell,
+			Status:       seed.status(),
+			Attempts:     seed.Attempts,
+			ExitCode:     seed.ExitCode,
+			WallTime:     seed.Duration.Seconds(),
+			UserCPU:      seed.Usage.UserCPU.Seconds(),
+			SysCPU:       seed.Usage.SysCPU.Seconds(),
+			MaxRSS:       seed.Usage.MaxRSS,
+			Reproduce:    seedCmdString(seed),
+			Stdout:       seed.Stdout,
+			Stderr:       seed.Stderr,
+			ExportParams: seed.ExportParams,
+			ExportState:  seed.ExportState,
+			PrevAttempts: seed.PrevAttempts,
+			Runs:         seed.Runs,
+			Stages:       seed.Stages,
+			Failure:      seed.Failure,
+		}
+	}
+	sort.Slice(report.Seeds, func(i, j int) bool {
+		if report.Seeds[i].Seed != report.Seeds[j].Seed {
+			return report.Seeds[i].Seed < report.Seeds[j].Seed
+		}
+		return report.Seeds[i].Cell < report.Seeds[j].Cell
+	})
+	return report
+}
+
+// writeReport writes the results of all seeds to fileName in the requested format.
+func writeReport(format, fileName string, results []Seed) (err error) {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return
+	}
+	defer func() {
+		cerr := file.Close()
+		if err == nil {
+			err = cerr
+		}
+	}()
+
+	report := buildReport(results)
+	switch format {
+	case reportJSON:
+		err = writeJSONReport(file, report)
+	case reportJUnit:
+		err = writeJUnitReport(file, report)
+	}
+	return
+}
+
+func writeJSONReport(w io.Writer, report runReport) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
+
+// JUnit XML schema, limited to the elements understood by most CI dashboards
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Skipped  int             `xml:"skipped,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Body    string `xml:",chardata"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr"`
+}
+
+func writeJUnitReport(w io.Writer, report runReport) error {
+	suite := junitTestSuite{
+		Name:  fmt.Sprintf("%s/%s", report.Package, report.TestName),
+		Tests: len(report.Seeds),
+		Cases: make([]junitTestCase, len(report.Seeds)),
+	}
+
+	var total time.Duration
+	for i, seed := range report.Seeds {
+		total += time.Duration(seed.WallTime * float64(time.Second))
+		testCase := junitTestCase{
+			Name:      fmt.Sprintf("%s/seed-%d", report.TestName, seed.Seed),
+			ClassName: report.Package,
+			Time:      fmt.Sprintf("%.3f", seed.WallTime),
+			SystemOut: fmt.Sprintf("stdout: %s\nstderr: %s\nexport params: %s\nexport state: %s\n"+
+				"user CPU: %.3fs\nsys CPU: %.3fs\nmax RSS: %d bytes\n",
+				seed.Stdout, seed.Stderr, seed.ExportParams, seed.ExportState, seed.UserCPU, seed.SysCPU, seed.MaxRSS),
+		}
+		if seed.Cell != "" {
+			testCase.Name = fmt.Sprintf("%s/%s/seed-%d", report.TestName, seed.Cell, seed.Seed)
+		}
+		if seed.Status == seedFlaky {
+			testCase.SystemOut += fmt.Sprintf("flaky: passed on attempt %d\n", seed.Attempts)
+		}
+		if seed.Status == seedInterrupted {
+			suite.Skipped++
+			testCase.Skipped = &junitSkipped{Message: "the run was interrupted while simulating the seed"}
+		}
+		if seed.Status == seedFailed || seed.Status == seedTimedOut {
+			suite.Failures++
+			failure := &junitFailure{
+				Message: fmt.Sprintf("seed %s failed with exit code %d", seed.name(), seed.ExitCode),
+				Type:    seed.Status,
+				Body:    "To reproduce run: " + seed.Reproduce,
+			}
+			if seed.Failure != nil {
+				failure.Type = seed.Failure.Category
+				if seed.Failure.Detail != "" {
+					failure.Message = fmt.Sprintf("seed %s: %s: %s", seed.name(), seed.Failure.Category, seed.Failure.Detail)
+				}
+				if len(seed.Failure.Stack) > 0 {
+					failure.Body += "\n\n" + strings.Join(seed.Failure.Stack, "\n")
+				}
+			}
+			testCase.Failure = failure
+		}
+		suite.Cases[i] = testCase
+	}
+	suite.Time = fmt.Sprintf("%.3f", total.Seconds())
+
+	if report.BuildFailure != nil {
+		suite.Tests++
+		suite.Failures++
+		suite.Cases = append(suite.Cases, junitTestCase{
+			Name:      report.TestName + "/build",
+			ClassName: report.Package,
+			Time:      "0.000",
+			Failure: &junitFailure{
+				Message: "the simulation test binary could not be built",
+				Type:    report.BuildFailure.Category,
+				Body:    report.BuildFailure.Detail,
+			},
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(junitTestSuites{Suites: []junitTestSuite{suite}}); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
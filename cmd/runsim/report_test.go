@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// reportSeeds holds a seed of every status, out of order.
+var reportSeeds = []Seed{
+	{Num: 32, Failed: true, ExitCode: 2, Duration: 3 * time.Second, Attempts: 1,
+		Failure: &failureInfo{Category: failurePanic, Detail: "boom", Stack: []string{"keeper.Delegate delegation.go:534"}}},
+	{Num: 7, Cell: "mainnet@500", Duration: 2 * time.Second, Attempts: 1},
+	{Num: 7, Cell: "mainnet@100", Flaky: true, Attempts: 2, Duration: time.Second},
+	{Num: 4, Interrupted: true},
+	{Num: 1, Failed: true, TimedOut: true, ExitCode: -1, Attempts: 1,
+		Failure: &failureInfo{Category: failureTimeout, Detail: "killed by runsim after 1h0m0s"}},
+}
+
+func useReportGlobals(t *testing.T) func() {
+	argv, err := parseCmdTemplate(defaultCmdTemplate)
+	require.NoError(t, err)
+	savedTemplate := cmdTemplate
+	cmdTemplate = argv
+	testname, pkgName, blocks, period = "TestFullAppSimulation", "./simapp", "500", "5"
+	return func() {
+		cmdTemplate, buildFailure = savedTemplate, nil
+		testname, pkgName, blocks, period = "", "", "", ""
+	}
+}
+
+func TestBuildReport(t *testing.T) {
+	defer useReportGlobals(t)()
+
+	report := buildReport(reportSeeds)
+	var names, statuses []string
+	for _, seed := range report.Seeds {
+		names = append(names, seed.name())
+		statuses = append(statuses, seed.Status)
+	}
+	require.Equal(t, []string{"1", "4", "7 [mainnet@100]", "7 [mainnet@500]", "32"}, names)
+	require.Equal(t, []string{seedTimedOut, seedInterrupted, seedFlaky, seedPassed, seedFailed}, statuses)
+	require.Equal(t, 3.0, report.Seeds[4].WallTime)
+	require.Contains(t, report.Seeds[4].Reproduce, "-Seed=32")
+}
+
+func TestJSONReportRoundTrip(t *testing.T) {
+	defer useReportGlobals(t)()
+
+	report := buildReport(reportSeeds)
+	var buf bytes.Buffer
+	require.NoError(t, writeJSONReport(&buf, report))
+	var decoded runReport
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+	require.Equal(t, report, decoded)
+}
+
+func TestJUnitReport(t *testing.T) {
+	defer useReportGlobals(t)()
+
+	var buf bytes.Buffer
+	require.NoError(t, writeJUnitReport(&buf, buildReport(reportSeeds)))
+	var suites junitTestSuites
+	require.NoError(t, xml.Unmarshal(buf.Bytes(), &suites))
+	require.Len(t, suites.Suites, 1)
+	suite := suites.Suites[0]
+	require.Equal(t, "./simapp/TestFullAppSimulation", suite.Name)
+	require.Equal(t, 5, suite.Tests)
+	require.Equal(t, 2, suite.Failures)
+	require.Equal(t, 1, suite.Skipped)
+	require.Equal(t, "6.000", suite.Time)
+
+	cases := make(map[string]junitTestCase)
+	for _, testCase := range suite.Cases {
+		cases[testCase.Name] = testCase
+	}
+	timedOut := cases["TestFullAppSimulation/seed-1"]
+	require.NotNil(t, timedOut.Failure)
+	require.Equal(t, failureTimeout, timedOut.Failure.Type)
+	require.Equal(t, "seed 1: test timeout: killed by runsim after 1h0m0s", timedOut.Failure.Message)
+
+	failed := cases["TestFullAppSimulation/seed-32"]
+	require.NotNil(t, failed.Failure)
+	require.Equal(t, failurePanic, failed.Failure.Type)
+	require.Contains(t, failed.Failure.Body, "To reproduce run: go test ./simapp -run TestFullAppSimulation")
+	require.Contains(t, failed.Failure.Body, "keeper.Delegate delegation.go:534")
+
+	interrupted := cases["TestFullAppSimulation/seed-4"]
+	require.Nil(t, interrupted.Failure)
+	require.NotNil(t, interrupted.Skipped)
+
+	flaky := cases["TestFullAppSimulation/mainnet@100/seed-7"]
+	require.Nil(t, flaky.Failure)
+	require.Nil(t, flaky.Skipped)
+	require.Contains(t, flaky.SystemOut, "flaky: passed on attempt 2")
+	require.Nil(t, cases["TestFullAppSimulation/mainnet@500/seed-7"].Failure)
+}
+
+func TestJUnitReportBuildFailure(t *testing.T) {
+	defer useReportGlobals(t)()
+	buildFailure = &failureInfo{Category: failureBuild, Detail: "simapp/app.go:12:2: undefined: foo"}
+
+	var buf bytes.Buffer
+	require.NoError(t, writeJUnitReport(&buf, buildReport(nil)))
+	var suites junitTestSuites
+	require.NoError(t, xml.Unmarshal(buf.Bytes(), &suites))
+	suite := suites.Suites[0]
+	require.Equal(t, 1, suite.Tests)
+	require.Equal(t, 1, suite.Failures)
+	require.Len(t, suite.Cases, 1)
+	require.Equal(t, "TestFullAppSimulation/build", suite.Cases[0].Name)
+	require.Equal(t, failureBuild, suite.Cases[0].Failure.Type)
+	require.Equal(t, "simapp/app.go:12:2: undefined: foo", suite.Cases[0].Failure.Body)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// resources used by a simulation process and the children it waited for,
+// i.e. the test binary spawned by go test
+type resourceUsage struct {
+	UserCPU time.Duration `json:"user_cpu"`
+	SysCPU  time.Duration `json:"sys_cpu"`
+	MaxRSS  int64         `json:"max_rss_bytes"`
+}
+
+func getResourceUsage(state *os.ProcessState) (usage resourceUsage) {
+	if state == nil {
+		return
+	}
+	usage.UserCPU = state.UserTime()
+	usage.SysCPU = state.SystemTime()
+	if rusage, ok := state.SysUsage().(*syscall.Rusage); ok {
+		usage.MaxRSS = int64(rusage.Maxrss)
+		// Linux reports the max RSS in kilobytes, darwin in bytes
+		if runtime.GOOS != "darwin" {
+			usage.MaxRSS *= 1024
+		}
+	}
+	return
+}
+
+func (usage resourceUsage) String() string {
+	return fmt.Sprintf("user %s, sys %s, max RSS %s",
+		usage.UserCPU.Round(time.Millisecond), usage.SysCPU.Round(time.Millisecond), formatBytes(usage.MaxRSS))
+}
+
+// buildResourceSummary sums up the wall time, CPU time and memory used by the seeds.
+func buildResourceSummary(results []Seed) string {
+	if len(results) == 0 {
+		return ""
+	}
+
+	var wall, user, sys time.Duration
+	var longest, hungriest Seed
+	for _, seed := range results {
+		wall += seed.Duration
+		user += seed.Usage.UserCPU
+		sys += seed.Usage.SysCPU
+		if seed.Duration > longest.Duration {
+			longest = seed
+		}
+		if seed.Usage.MaxRSS > hungriest.Usage.MaxRSS {
+			hungriest = seed
+		}
+	}
+
+	var summary strings.Builder
+	summary.WriteString("Resources:\n")
+	summary.WriteString(fmt.Sprintf("wall time: avg %s, max %s (seed %s)\n",
+		(wall / time.Duration(len(results))).Round(time.Second), longest.Duration.Round(time.Second), longest.name()))
+	summary.WriteString(fmt.Sprintf("CPU time: user %s, sys %s\n", user.Round(time.Second), sys.Round(time.Second)))
+	summary.WriteString(fmt.Sprintf("peak RSS: max %s (seed %s)\n", formatBytes(hungriest.Usage.MaxRSS), hungriest.name()))
+	return summary.String()
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// name of the first stage of an import/export round trip, which runs the test
+// given on the command line and exports the state the following stages import
+const stageExport = "export"
+
+// default import stages of -ImportExport, as name=test pairs
+const defaultImportTests = "import=TestAppImportExport,after-import=TestAppSimulationAfterImport"
+
+// simStage is a stage of an import/export round trip.
+type simStage struct {
+	Name string
+	Test string
+}
+
+// the stages of an import/export round trip, nil unless enabled with -ImportExport
+var importStages []simStage
+
+// parseImportTests parses the stages run after the export stage from a
+// comma-separated list of tests, each optionally named as in name=test.
+func parseImportTests(list string) (stages []simStage, err error) {
+	stages = []simStage{{Name: stageExport, Test: testname}}
+	names := map[string]bool{stageExport: true}
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		stage := simStage{Name: item, Test: item}
+		if i := strings.Index(item, "="); i >= 0 {
+			stage = simStage{Name: strings.TrimSpace(item[:i]), Test: strings.TrimSpace(item[i+1:])}
+		}
+		if stage.Name == "" || stage.Test == "" {
+			return nil, fmt.Errorf("invalid import test %q", item)
+		}
+		if names[stage.Name] {
+			return nil, fmt.Errorf("duplicate import stage %q", stage.Name)
+		}
+		names[stage.Name] = true
+		stages = append(stages, stage)
+	}
+	if len(stages) == 1 {
+		return nil, fmt.Errorf("no import tests")
+	}
+	return
+}
+
+// checkImportCmd checks that the simulation command template feeds the state
+// exported by the previous stage to the import stages, as a custom
+// -CmdTemplate has to with {{.ImportStatePath}}.
+func checkImportCmd() error {
+	const state = "\x00import-state"
+	args, err := expandCmdTemplate(cmdTemplate, cmdParams{ImportStatePath: state})
+	if err != nil {
+		return err
+	}
+	for _, arg := range args {
+		if strings.Contains(arg, state) {
+			return nil
+		}
+	}
+	return fmt.Errorf("the command template doesn't use {{.ImportStatePath}}, the import stages would not import the exported state")
+}
+
+// stageTest returns the test run by a stage, the command line test outside of round trips.
+func stageTest(name string) string {
+	for _, stage := range importStages {
+		if stage.Name == name {
+			return stage.Test
+		}
+	}
+	return testname
+}
+
+// runRoundTrip runs the stages of an import/export round trip for a seed, each
+// stage simulating from the state exported by the previous one, and stops
+// at the first stage that fails. The returned seed is the one of the last stage
+// run, its Stage is the stage that failed, and Stages holds the logs and
+// exports of the stages before it. Resource usage adds up over the stages.
+func runRoundTrip(ctx context.Context, workerID int, seed Seed) (result Seed, err error) {
+	var stages []string
+	var total resourceUsage
+	var duration time.Duration
+	var flaky bool
+	prev := seed
+	for i, stage := range importStages {
+		run := seed
+		run.Stage = stage.Name
+		if i > 0 {
+			importGenesis := buildStageFileName(prev.ExportState, "genesis")
+			if werr := writeImportGenesis(prev.ExportState, importGenesis); werr != nil {
+				result = prev
+				result.Failure = &failureInfo{Category: failureUnknown, Stage: prev.Stage,
+					Detail: fmt.Sprintf("stage %s exported no state to import: %v", prev.Stage, werr)}
+				err = fmt.Errorf("%s: %s", result.Failure.Stage, result.Failure.Detail)
+				break
+			}
+			stages = append(stages, prev.Stderr, prev.Stdout, prev.ExportParams, prev.ExportState, importGenesis)
+			stages = append(stages, prev.PrevAttempts...)
+
+			run.Stdout = buildStageFileName(seed.Stdout, stage.Name)
+			run.Stderr = buildStageFileName(seed.Stderr, stage.Name)
+			run.ExportParams = buildStageFileName(seed.ExportParams, stage.Name)
+			run.ExportState = buildStageFileName(seed.ExportState, stage.Name)
+			run.ImportParams, run.ImportState = prev.ExportParams, importGenesis
+			log.Printf("[W%d] Seed %s: stage %s (%s)", workerID, seed.name(), stage.Name, stage.Test)
+		}
+
+		result, err = runSeed(ctx, workerID, run)
+		duration += result.Duration
+		total.UserCPU += result.Usage.UserCPU
+		total.SysCPU += result.Usage.SysCPU
+		if result.Usage.MaxRSS > total.MaxRSS {
+			total.MaxRSS = result.Usage.MaxRSS
+		}
+		flaky = flaky || result.Flaky
+		if err != nil {
+			if result.Failure != nil {
+				result.Failure.Stage = stage.Name
+			}
+			break
+		}
+		prev = result
+	}
+
+	result.Stages = stages
+	result.Duration, result.Usage = duration, total
+	result.Flaky = err == nil && flaky
+	return
+}
+
+// chain ID of the genesis documents the import stages simulate from, the one of the SDK simulations
+const simChainID = "simulation-app"
+
+// writeImportGenesis wraps the app state exported by a stage in a genesis
+// document, the simulations only import the state as a -Genesis file. The
+// state is copied as is, it can be large.
+func writeImportGenesis(stateFile, genesisFile string) (err error) {
+	state, err := os.Open(stateFile)
+	if err != nil {
+		return
+	}
+	defer state.Close()
+	if info, err := state.Stat(); err != nil || info.Size() == 0 {
+		return fmt.Errorf("%s is empty", stateFile)
+	}
+	file, err := os.Create(genesisFile)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if cerr := file.Close(); err == nil {
+			err = cerr
+		}
+	}()
+
+	header, err := json.Marshal(struct {
+		GenesisTime time.Time `json:"genesis_time"`
+		ChainID     string    `json:"chain_id"`
+	}{time.Now().UTC(), simChainID})
+	if err != nil {
+		return
+	}
+	w := bufio.NewWriter(file)
+	// {"genesis_time":...,"chain_id":...,"app_state":<state>}
+	_, _ = w.Write(header[:len(header)-1])
+	_, _ = w.WriteString(`,"app_state":`)
+	if _, err = io.Copy(w, state); err != nil {
+		return
+	}
+	_, _ = w.WriteString("}\n")
+	return w.Flush()
+}
+
+func buildStageFileName(fileName, stage string) string {
+	ext := filepath.Ext(fileName)
+	return fmt.Sprintf("%s-%s%s", fileName[:len(fileName)-len(ext)], stage, ext)
+}
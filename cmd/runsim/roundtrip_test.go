@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseImportTests(t *testing.T) {
+	testname = "TestFullAppSimulation"
+	defer func() { testname = "" }()
+
+	stages, err := parseImportTests(defaultImportTests)
+	require.NoError(t, err)
+	require.Equal(t, []simStage{
+		{Name: stageExport, Test: "TestFullAppSimulation"},
+		{Name: "import", Test: "TestAppImportExport"},
+		{Name: "after-import", Test: "TestAppSimulationAfterImport"},
+	}, stages)
+
+	stages, err = parseImportTests("TestAppImportExport, ")
+	require.NoError(t, err)
+	require.Equal(t, simStage{Name: "TestAppImportExport", Test: "TestAppImportExport"}, stages[1])
+
+	for _, list := range []string{"", "import=", "=TestAppImportExport", "export=TestAppImportExport", "a=TestA,a=TestB"} {
+		_, err := parseImportTests(list)
+		require.Error(t, err, list)
+	}
+}
+
+func TestImportStageCmdArgs(t *testing.T) {
+	savedTemplate := cmdTemplate
+	defer func() {
+		cmdTemplate, importStages, testname, pkgName, genesis, blocks, period = savedTemplate, nil, "", "", "", "", ""
+	}()
+	testname, pkgName, genesis, blocks, period = "TestFullAppSimulation", "./simapp", "genesis.json", "100", "5"
+	var err error
+	importStages, err = parseImportTests(defaultImportTests)
+	require.NoError(t, err)
+
+	for _, text := range []string{defaultCmdTemplate, precompiledCmdTemplate} {
+		cmdTemplate, err = parseCmdTemplate(text)
+		require.NoError(t, err)
+		require.NoError(t, checkImportCmd())
+
+		export := Seed{Num: 7, Stage: stageExport, ExportState: "state.json", ExportParams: "params.json"}
+		args := seedCmdArgs(export)
+		require.Contains(t, args, "-Genesis=genesis.json")
+
+		// the simulation rejects -Params along with -Genesis
+		imp := Seed{Num: 7, Stage: "import", ExportState: "state-import.json", ExportParams: "params-import.json",
+			ImportState: "state-genesis.json", ImportParams: "params.json"}
+		args = seedCmdArgs(imp)
+		require.Contains(t, args, "TestAppImportExport")
+		require.Contains(t, args, "-Genesis=state-genesis.json")
+		require.NotContains(t, args, "-Params=params.json")
+		require.Contains(t, args, "state-import.json")
+	}
+
+	cmdTemplate, err = parseCmdTemplate("go test -run {{.TestName}} -Genesis={{.Genesis}} -Params={{.ImportParamsPath}}")
+	require.NoError(t, err)
+	require.Error(t, checkImportCmd())
+}
+
+func TestWriteImportGenesis(t *testing.T) {
+	dir, err := ioutil.TempDir("", "runsim-roundtrip")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	stateFile := filepath.Join(dir, "sim_state-7.json")
+	genesisFile := buildStageFileName(stateFile, "genesis")
+	require.NoError(t, ioutil.WriteFile(stateFile, []byte(`{"bank":{"supply":[]}}`), 0644))
+	require.NoError(t, writeImportGenesis(stateFile, genesisFile))
+
+	var doc struct {
+		GenesisTime time.Time       `json:"genesis_time"`
+		ChainID     string          `json:"chain_id"`
+		AppState    json.RawMessage `json:"app_state"`
+	}
+	content, err := ioutil.ReadFile(genesisFile)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(content, &doc))
+	require.Equal(t, simChainID, doc.ChainID)
+	require.False(t, doc.GenesisTime.IsZero())
+	require.JSONEq(t, `{"bank":{"supply":[]}}`, string(doc.AppState))
+
+	require.NoError(t, ioutil.WriteFile(stateFile, nil, 0644))
+	require.Error(t, writeImportGenesis(stateFile, genesisFile))
+	require.Error(t, writeImportGenesis(filepath.Join(dir, "missing.json"), genesisFile))
+}
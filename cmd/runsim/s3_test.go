@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeS3 is a minimal S3-compatible server handling the object and multipart upload
+// requests runsim makes. It rejects uploads without a matching Content-MD5 and
+// fails the first attempt of every second part to exercise retries.
+type fakeS3 struct {
+	mtx      sync.Mutex
+	objects  map[string][]byte
+	metadata map[string]string
+	parts    map[int][]byte
+	failed   map[int]bool
+}
+
+func newFakeS3() *fakeS3 {
+	return &fakeS3{
+		objects:  map[string][]byte{},
+		metadata: map[string]string{},
+		parts:    map[int][]byte{},
+		failed:   map[int]bool{},
+	}
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if r.Method == http.MethodPut {
+		sum := md5.Sum(body)
+		if r.Header.Get("Content-MD5") != base64.StdEncoding.EncodeToString(sum[:]) {
+			writeS3Error(w, http.StatusBadRequest, "BadDigest")
+			return
+		}
+	}
+
+	key := strings.TrimPrefix(r.URL.Path, "/")
+	query := r.URL.Query()
+	switch {
+	case r.Method == http.MethodPost && hasQuery(query, "uploads"):
+		f.parts = map[int][]byte{}
+		f.metadata[key] = r.Header.Get("X-Amz-Meta-Sha256")
+		fmt.Fprintf(w, "<InitiateMultipartUploadResult><UploadId>upload-1</UploadId></InitiateMultipartUploadResult>")
+	case r.Method == http.MethodPut && query.Get("uploadId") != "":
+		part, _ := strconv.Atoi(query.Get("partNumber"))
+		if part%2 == 0 && !f.failed[part] {
+			f.failed[part] = true
+			writeS3Error(w, http.StatusInternalServerError, "InternalError")
+			return
+		}
+		f.parts[part] = body
+		w.Header().Set("ETag", fmt.Sprintf(`"part-%d"`, part))
+	case r.Method == http.MethodPost && query.Get("uploadId") != "":
+		var nums []int
+		for num := range f.parts {
+			nums = append(nums, num)
+		}
+		sort.Ints(nums)
+		var object []byte
+		for _, num := range nums {
+			object = append(object, f.parts[num]...)
+		}
+		f.objects[key] = object
+		fmt.Fprintf(w, `<CompleteMultipartUploadResult><ETag>"object"</ETag></CompleteMultipartUploadResult>`)
+	case r.Method == http.MethodDelete:
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPut:
+		f.objects[key] = body
+		f.metadata[key] = r.Header.Get("X-Amz-Meta-Sha256")
+		w.Header().Set("ETag", `"object"`)
+	default:
+		writeS3Error(w, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+func hasQuery(query url.Values, name string) bool {
+	_, ok := query[name]
+	return ok
+}
+
+func writeS3Error(w http.ResponseWriter, status int, code string) {
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "<Error><Code>%s</Code><Message>%s</Message></Error>", code, code)
+}
+
+func TestS3StoreUpload(t *testing.T) {
+	server := httptest.NewServer(newFakeS3())
+	defer server.Close()
+	fake := server.Config.Handler.(*fakeS3)
+
+	dir, err := ioutil.TempDir("", "runsim-s3")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	require.NoError(t, os.Setenv("AWS_ACCESS_KEY_ID", "test"))
+	require.NoError(t, os.Setenv("AWS_SECRET_ACCESS_KEY", "test"))
+	awsRegion, s3Endpoint, logBucket, s3PartSize = "us-east-1", server.URL, "sim-logs-test", s3manager.MinUploadPartSize
+	defer func() { awsRegion, s3Endpoint, logBucket, s3PartSize, presignLinks = "", "", "", 64<<20, 0 }()
+
+	store, err := newS3Store()
+	require.NoError(t, err)
+
+	// large enough for a 3 parts upload
+	content := make([]byte, 2*s3manager.MinUploadPartSize+1024)
+	_, err = rand.Read(content)
+	require.NoError(t, err)
+	exports := filepath.Join(dir, "exports.zip")
+	require.NoError(t, ioutil.WriteFile(exports, content, 0644))
+	checksum, err := sha256File(exports)
+	require.NoError(t, err)
+
+	link, err := store.upload(exports, "debug/host/exports.zip")
+	require.NoError(t, err)
+	require.Equal(t, server.URL+"/sim-logs-test/debug/host/exports.zip", link)
+	require.True(t, bytes.Equal(content, fake.objects["sim-logs-test/debug/host/exports.zip"]))
+	require.Equal(t, checksum, fake.metadata["sim-logs-test/debug/host/exports.zip"])
+	require.True(t, fake.failed[2], "part 2 should have been retried")
+
+	// small files go in a single request
+	okZip := filepath.Join(dir, "ok.zip")
+	require.NoError(t, ioutil.WriteFile(okZip, []byte("logs"), 0644))
+	presignLinks = time.Hour
+	link, err = store.upload(okZip, "debug/host/ok.zip")
+	require.NoError(t, err)
+	require.Equal(t, "logs", string(fake.objects["sim-logs-test/debug/host/ok.zip"]))
+	require.True(t, strings.HasPrefix(link, server.URL+"/sim-logs-test/debug/host/ok.zip?"), link)
+	require.Contains(t, link, "X-Amz-Signature=")
+	require.Contains(t, link, "X-Amz-Expires=3600")
+}
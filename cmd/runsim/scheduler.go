@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const memPollInterval = 10 * time.Second
+
+// the memory scheduler is nil unless enabled with -MemSchedule, all methods are no-ops on a nil scheduler
+var memSched *memScheduler
+
+// memScheduler holds seeds back while starting one more simulation would push
+// the host's memory usage over the limit. Every running seed is expected to
+// reach the estimated peak RSS: the larger of -SeedMem and the highest peak
+// measured so far. The part of that estimate that a seed hasn't used yet is
+// counted as committed memory.
+type memScheduler struct {
+	mtx      sync.Mutex
+	limit    float64
+	estimate int64
+	peak     int64
+
+	// pid of the simulation run by each worker: 0 while it's starting, -1 when idle
+	slots []int
+}
+
+func newMemScheduler(workers int, limit float64, estimate int64) *memScheduler {
+	slots := make([]int, workers)
+	for i := range slots {
+		slots[i] = -1
+	}
+	return &memScheduler{limit: limit, estimate: estimate, slots: slots}
+}
+
+// admit blocks until there is enough memory to start the seed, it returns false if ctx is cancelled meanwhile.
+func (s *memScheduler) admit(ctx context.Context, workerID int, seed Seed) bool {
+	if s == nil {
+		return true
+	}
+
+	var heldSince, lastLog time.Time
+	for {
+		s.mtx.Lock()
+		ok, reason := s.fits()
+		if ok {
+			s.slots[workerID] = 0
+			s.mtx.Unlock()
+			if !heldSince.IsZero() {
+				log.Printf("[W%d] Seed %s: released after being held back for %s",
+					workerID, seed.name(), time.Since(heldSince).Round(time.Second))
+			}
+			return true
+		}
+		s.mtx.Unlock()
+
+		if heldSince.IsZero() {
+			heldSince = time.Now()
+		}
+		if time.Since(lastLog) >= time.Minute {
+			log.Printf("[W%d] Seed %s: held back, %s", workerID, seed.name(), reason)
+			lastLog = time.Now()
+		}
+		select {
+		case <-time.After(memPollInterval):
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+// fits tells whether a new seed can start, s.mtx must be held.
+func (s *memScheduler) fits() (bool, string) {
+	running := 0
+	for _, pid := range s.slots {
+		if pid >= 0 {
+			running++
+		}
+	}
+	// always let one seed run, or a bad estimate would stall the whole run
+	if running == 0 {
+		return true, ""
+	}
+
+	total, available, err := readMeminfo()
+	if err != nil {
+		log.Printf("WARNING: memory scheduling disabled: %v", err)
+		return true, ""
+	}
+
+	estimate := s.estimate
+	if s.peak > estimate {
+		estimate = s.peak
+	}
+
+	committed := total - available
+	rss := processTreeRSS()
+	for _, pid := range s.slots {
+		if pid < 0 {
+			continue
+		}
+		if remaining := estimate - rss[pid]; remaining > 0 {
+			committed += remaining
+		}
+	}
+
+	limit := int64(s.limit * float64(total))
+	if committed+estimate <= limit {
+		return true, ""
+	}
+	return false, fmt.Sprintf("%s committed + %s estimated > %s limit (%d seeds running)",
+		formatBytes(committed), formatBytes(estimate), formatBytes(limit), running)
+}
+
+func (s *memScheduler) setPid(workerID, pid int) {
+	if s == nil {
+		return
+	}
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.slots[workerID] = pid
+}
+
+// done frees the worker's slot and records the seed's peak RSS.
+func (s *memScheduler) done(workerID int, seed Seed) {
+	if s == nil {
+		return
+	}
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.slots[workerID] = -1
+	if seed.Usage.MaxRSS > s.peak {
+		s.peak = seed.Usage.MaxRSS
+	}
+}
+
+// readMeminfo returns the total and available memory of the host in bytes.
+func readMeminfo() (total, available int64, err error) {
+	file, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	return parseMeminfo(file)
+}
+
+func parseMeminfo(r io.Reader) (total, available int64, err error) {
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		var value int64
+		if value, err = strconv.ParseInt(fields[1], 10, 64); err != nil {
+			return
+		}
+		// values are in kB
+		switch fields[0] {
+		case "MemTotal:":
+			total = value * 1024
+		case "MemAvailable:":
+			available = value * 1024
+		}
+	}
+	if err = sc.Err(); err != nil {
+		return
+	}
+	if total == 0 || available == 0 {
+		err = fmt.Errorf("MemTotal or MemAvailable missing from /proc/meminfo")
+	}
+	return
+}
+
+// processTreeRSS returns the resident memory of every process plus that of
+// all its descendants, so that the go test process accounts for the test
+// binary it runs.
+func processTreeRSS() map[int]int64 {
+	stats, _ := filepath.Glob("/proc/[0-9]*/stat")
+	rss := make(map[int]int64, len(stats))
+	parents := make(map[int]int, len(stats))
+	pageSize := int64(os.Getpagesize())
+	for _, stat := range stats {
+		content, err := ioutil.ReadFile(stat)
+		if err != nil {
+			// the process exited in the meantime
+			continue
+		}
+		pid, ppid, pages, err := parseProcStat(string(content))
+		if err != nil {
+			continue
+		}
+		rss[pid] += pages * pageSize
+		parents[pid] = ppid
+	}
+
+	tree := make(map[int]int64, len(rss))
+	for pid, bytes := range rss {
+		// add the process' memory to itself and all its ancestors
+		for p, seen := pid, 0; p > 0 && seen < len(rss); p, seen = parents[p], seen+1 {
+			tree[p] += bytes
+		}
+	}
+	return tree
+}
+
+// parseProcStat extracts the pid, parent pid and RSS in pages from /proc/<pid>/stat.
+func parseProcStat(stat string) (pid, ppid int, rssPages int64, err error) {
+	// the command name may contain spaces and parentheses, the fields after it are well-formed
+	open, end := strings.IndexByte(stat, '('), strings.LastIndexByte(stat, ')')
+	if open < 0 || end < open {
+		return 0, 0, 0, fmt.Errorf("malformed stat %q", stat)
+	}
+	if pid, err = strconv.Atoi(strings.TrimSpace(stat[:open])); err != nil {
+		return
+	}
+	// fields after the command: state(3) ppid(4) ... rss(24)
+	fields := strings.Fields(stat[end+1:])
+	if len(fields) < 22 {
+		return 0, 0, 0, fmt.Errorf("malformed stat %q", stat)
+	}
+	if ppid, err = strconv.Atoi(fields[1]); err != nil {
+		return
+	}
+	rssPages, err = strconv.ParseInt(fields[21], 10, 64)
+	return
+}
+
+// parseBytes parses a memory size such as 512MiB, 4G or 1073741824.
+func parseBytes(size string) (int64, error) {
+	size = strings.TrimSpace(size)
+	units := []struct {
+		suffix string
+		mult   int64
+	}{
+		{"KiB", 1 << 10}, {"MiB", 1 << 20}, {"GiB", 1 << 30}, {"TiB", 1 << 40},
+		{"K", 1 << 10}, {"M", 1 << 20}, {"G", 1 << 30}, {"T", 1 << 40}, {"B", 1},
+	}
+	for _, unit := range units {
+		if strings.HasSuffix(size, unit.suffix) {
+			value, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(size, unit.suffix)), 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid size %q", size)
+			}
+			return int64(value * float64(unit.mult)), nil
+		}
+	}
+	value, err := strconv.ParseInt(size, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", size)
+	}
+	return value, nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseBytes(t *testing.T) {
+	for size, expected := range map[string]int64{
+		"0":        0,
+		"1024":     1024,
+		"512MiB":   512 << 20,
+		"4G":       4 << 30,
+		"1.5 GiB":  3 << 29,
+		"100B":     100,
+		" 2KiB ":   2048,
+		"0.25TiB":  1 << 38,
+		"10000000": 10000000,
+	} {
+		n, err := parseBytes(size)
+		require.NoError(t, err, size)
+		require.Equal(t, expected, n, size)
+	}
+
+	for _, size := range []string{"", "GiB", "4 GB", "lots"} {
+		_, err := parseBytes(size)
+		require.Error(t, err, size)
+	}
+}
+
+func TestParseMeminfo(t *testing.T) {
+	total, available, err := parseMeminfo(strings.NewReader(
+		"MemTotal:       65861256 kB\nMemFree:         1041520 kB\nMemAvailable:   40123456 kB\nBuffers: 1 kB\n"))
+	require.NoError(t, err)
+	require.Equal(t, int64(65861256*1024), total)
+	require.Equal(t, int64(40123456*1024), available)
+
+	_, _, err = parseMeminfo(strings.NewReader("MemTotal:       65861256 kB\n"))
+	require.Error(t, err)
+}
+
+func TestParseProcStat(t *testing.T) {
+	pid, ppid, rss, err := parseProcStat("4242 (sim.test (x) y) S 4200 4242 4200 0 -1 4194560 4096 0 0 0 " +
+		"120 30 0 0 20 0 38 0 1234567 4096000000 25600 18446744073709551615 1 1 0 0 0 0 0 0 0 0 0 0 17 3 0 0 0 0 0")
+	require.NoError(t, err)
+	require.Equal(t, 4242, pid)
+	require.Equal(t, 4200, ppid)
+	require.Equal(t, int64(25600), rss)
+
+	_, _, _, err = parseProcStat("4242 sim.test S")
+	require.Error(t, err)
+}
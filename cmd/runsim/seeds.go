@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// upper bound of the generated random seeds
+const maxRandomSeed = 1 << 31
+
+// collectSeeds builds the list of seeds to run from all the seed sources given
+// on the command line, in order and without duplicates. defaults is returned
+// when there are none.
+func collectSeeds(defaults []int) ([]int, error) {
+	var collected []int
+	sources := 0
+
+	seedOverrideList = strings.TrimSpace(seedOverrideList)
+	if seedOverrideList != "" {
+		sources++
+		list, err := buildSeedList(seedOverrideList)
+		if err != nil {
+			return nil, err
+		}
+		collected = append(collected, list...)
+	}
+
+	if seedFile != "" {
+		sources++
+		list, err := readSeedFile(seedFile)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("Read %d seeds from %s", len(list), seedFile)
+		collected = append(collected, list...)
+	}
+
+	if randomSeeds > 0 {
+		sources++
+		if masterSeed == 0 {
+			masterSeed = time.Now().UnixNano()
+		}
+		log.Printf("Generating %d random seeds from master seed %d", randomSeeds, masterSeed)
+		collected = append(collected, buildRandomSeeds(masterSeed, randomSeeds)...)
+	}
+
+	if replayPath != "" {
+		sources++
+		list, err := failedSeeds(replayPath)
+		if err != nil {
+			return nil, err
+		}
+		if len(list) == 0 {
+			return nil, fmt.Errorf("%s contains no failed seeds", replayPath)
+		}
+		log.Printf("Replaying %d failed seeds from %s", len(list), replayPath)
+		collected = append(collected, list...)
+	}
+
+	if sources == 0 {
+		return defaults, nil
+	}
+	return uniqueSeeds(collected), nil
+}
+
+// buildSeedList parses a comma-separated list of seeds and inclusive seed ranges, e.g. "1,7,100-200".
+func buildSeedList(seeds string) ([]int, error) {
+	strSeedsLst := strings.Split(seeds, ",")
+	if len(strSeedsLst) == 0 {
+		return nil, fmt.Errorf("seeds was empty")
+	}
+	var intSeeds []int
+	for _, seedStr := range strSeedsLst {
+		seedStr = strings.TrimSpace(seedStr)
+		if from, to, ok := splitSeedRange(seedStr); ok {
+			first, err := strconv.Atoi(from)
+			if err != nil {
+				return nil, fmt.Errorf("cannot convert seed range %q: %v", seedStr, err)
+			}
+			last, err := strconv.Atoi(to)
+			if err != nil {
+				return nil, fmt.Errorf("cannot convert seed range %q: %v", seedStr, err)
+			}
+			if last < first {
+				return nil, fmt.Errorf("seed range %q is empty", seedStr)
+			}
+			for seed := first; seed <= last; seed++ {
+				intSeeds = append(intSeeds, seed)
+			}
+			continue
+		}
+		intSeed, err := strconv.Atoi(seedStr)
+		if err != nil {
+			return nil, fmt.Errorf("cannot convert seed to integer: %v", err)
+		}
+		intSeeds = append(intSeeds, intSeed)
+	}
+	return intSeeds, nil
+}
+
+// splitSeedRange splits "100-200" into its bounds.
+func splitSeedRange(s string) (from, to string, ok bool) {
+	if len(s) < 2 {
+		return
+	}
+	// a leading minus sign is part of the first bound
+	i := strings.Index(s[1:], "-") + 1
+	if i == 0 {
+		return
+	}
+	return strings.TrimSpace(s[:i]), strings.TrimSpace(s[i+1:]), true
+}
+
+// readSeedFile reads seeds and seed ranges from a file, one or more comma-separated
+// per line. Blank lines and lines starting with # are ignored.
+func readSeedFile(fileName string) ([]int, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var seeds []int
+	sc := bufio.NewScanner(file)
+	for lineNum := 1; sc.Scan(); lineNum++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		list, err := buildSeedList(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", fileName, lineNum, err)
+		}
+		seeds = append(seeds, list...)
+	}
+	return seeds, sc.Err()
+}
+
+// buildRandomSeeds generates n distinct seeds, the same master seed always yields the same seeds.
+func buildRandomSeeds(master int64, n int) []int {
+	r := rand.New(rand.NewSource(master))
+	seen := make(map[int]bool, n)
+	seeds := make([]int, 0, n)
+	for len(seeds) < n {
+		seed := r.Intn(maxRandomSeed)
+		if !seen[seed] {
+			seen[seed] = true
+			seeds = append(seeds, seed)
+		}
+	}
+	return seeds
+}
+
+// failedSeeds returns the seeds that failed or timed out in a previous run,
+// read from its JSON report or its journal.
+func failedSeeds(fileName string) ([]int, error) {
+	content, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	var seeds []int
+	failed := func(status string) bool { return status == seedFailed || status == seedTimedOut }
+
+	// a report is a single JSON object with a list of seeds, a journal has one object per line
+	var fields map[string]json.RawMessage
+	if err := json.NewDecoder(bytes.NewReader(content)).Decode(&fields); err == nil {
+		if _, ok := fields["seeds"]; ok {
+			var report runReport
+			if err := json.Unmarshal(content, &report); err != nil {
+				return nil, fmt.Errorf("%s: %v", fileName, err)
+			}
+			for _, seed := range report.Seeds {
+				if failed(seed.Status) {
+					seeds = append(seeds, seed.Seed)
+				}
+			}
+			return seeds, nil
+		}
+	}
+
+	entries, err := loadJournal(fileName)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if failed(entry.Status) {
+			seeds = append(seeds, entry.Seed)
+		}
+	}
+	return seeds, nil
+}
+
+func uniqueSeeds(seeds []int) []int {
+	seen := make(map[int]bool, len(seeds))
+	unique := seeds[:0]
+	for _, seed := range seeds {
+		if !seen[seed] {
+			seen[seed] = true
+			unique = append(unique, seed)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildSeedList(t *testing.T) {
+	seeds, err := buildSeedList("1, 7,100-103,-2, 5 - 6")
+	require.NoError(t, err)
+	require.Equal(t, []int{1, 7, 100, 101, 102, 103, -2, 5, 6}, seeds)
+
+	for _, list := range []string{"", "1,a", "200-100", "1-b"} {
+		_, err := buildSeedList(list)
+		require.Error(t, err, list)
+	}
+}
+
+func TestBuildRandomSeeds(t *testing.T) {
+	seeds := buildRandomSeeds(42, 50)
+	require.Len(t, seeds, 50)
+	require.Equal(t, seeds, buildRandomSeeds(42, 50))
+	require.Len(t, uniqueSeeds(append([]int(nil), seeds...)), 50)
+	require.NotEqual(t, seeds, buildRandomSeeds(43, 50))
+}
+
+func TestReadSeedFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "runsim-seeds")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	fileName := filepath.Join(dir, "seeds")
+	require.NoError(t, ioutil.WriteFile(fileName, []byte("# nightly seeds\n1\n\n4,9\n10-12\n"), 0644))
+	seeds, err := readSeedFile(fileName)
+	require.NoError(t, err)
+	require.Equal(t, []int{1, 4, 9, 10, 11, 12}, seeds)
+
+	require.NoError(t, ioutil.WriteFile(fileName, []byte("1\nx\n"), 0644))
+	_, err = readSeedFile(fileName)
+	require.EqualError(t, err, fileName+`:2: cannot convert seed to integer: strconv.Atoi: parsing "x": invalid syntax`)
+}
+
+func TestFailedSeeds(t *testing.T) {
+	dir, err := ioutil.TempDir("", "runsim-seeds")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	report := filepath.Join(dir, "report.json")
+	require.NoError(t, ioutil.WriteFile(report, []byte(`{
+  "test_name": "TestFullAppSimulation",
+  "seeds": [
+    {"seed": 1, "status": "passed"},
+    {"seed": 2, "status": "failed"},
+    {"seed": 3, "status": "flaky"},
+    {"seed": 4, "status": "timed out"}
+  ]
+}
+`), 0644))
+	seeds, err := failedSeeds(report)
+	require.NoError(t, err)
+	require.Equal(t, []int{2, 4}, seeds)
+
+	journal := filepath.Join(dir, "journal")
+	require.NoError(t, ioutil.WriteFile(journal, []byte(`{"seed":5,"status":"running"}
+{"seed":6,"status":"running"}
+{"seed":5,"status":"failed"}
+{"seed":6,"status":"passed"}
+{"seed":7,"status":"interrupted"}
+`), 0644))
+	seeds, err = failedSeeds(journal)
+	require.NoError(t, err)
+	require.Equal(t, []int{5}, seeds)
+}
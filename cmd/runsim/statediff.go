@@ -0,0 +1,323 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// kinds of differences between two JSON documents
+const (
+	diffChanged = "changed"
+	diffAdded   = "added"
+	diffRemoved = "removed"
+)
+
+// jsonDifference is a value that differs between two JSON documents. Path
+// holds the object keys (strings) and array indexes (ints) leading to it.
+type jsonDifference struct {
+	Kind     string
+	Path     []interface{}
+	Old, New interface{}
+}
+
+// readJSON decodes a JSON file keeping numbers as they are written, exported
+// state is full of integers too large for a float64.
+func readJSON(fileName string) (doc interface{}, err error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	dec := json.NewDecoder(file)
+	dec.UseNumber()
+	if err = dec.Decode(&doc); err != nil {
+		err = fmt.Errorf("%s: %v", fileName, err)
+	}
+	return
+}
+
+// diffJSON walks two decoded JSON documents in key order and calls visit for
+// every difference, until visit returns false. It returns false if it was stopped.
+func diffJSON(a, b interface{}, path []interface{}, visit func(jsonDifference) bool) bool {
+	sub := func(elem interface{}) []interface{} {
+		return append(append([]interface{}(nil), path...), elem)
+	}
+
+	switch a := a.(type) {
+	case map[string]interface{}:
+		b, ok := b.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(a)+len(b))
+		for key := range a {
+			keys = append(keys, key)
+		}
+		for key := range b {
+			if _, ok := a[key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			va, inA := a[key]
+			vb, inB := b[key]
+			var cont bool
+			switch {
+			case !inB:
+				cont = visit(jsonDifference{Kind: diffRemoved, Path: sub(key), Old: va})
+			case !inA:
+				cont = visit(jsonDifference{Kind: diffAdded, Path: sub(key), New: vb})
+			default:
+				cont = diffJSON(va, vb, sub(key), visit)
+			}
+			if !cont {
+				return false
+			}
+		}
+		return true
+
+	case []interface{}:
+		b, ok := b.([]interface{})
+		if !ok {
+			break
+		}
+		for i := 0; i < len(a) || i < len(b); i++ {
+			var cont bool
+			switch {
+			case i >= len(b):
+				cont = visit(jsonDifference{Kind: diffRemoved, Path: sub(i), Old: a[i]})
+			case i >= len(a):
+				cont = visit(jsonDifference{Kind: diffAdded, Path: sub(i), New: b[i]})
+			default:
+				cont = diffJSON(a[i], b[i], sub(i), visit)
+			}
+			if !cont {
+				return false
+			}
+		}
+		return true
+	}
+
+	if reflect.DeepEqual(a, b) {
+		return true
+	}
+	return visit(jsonDifference{Kind: diffChanged, Path: path, Old: a, New: b})
+}
+
+// formatPath renders a path as e.g. modules.staking.validators[3].tokens.
+func formatPath(path []interface{}) string {
+	var s strings.Builder
+	for _, elem := range path {
+		switch elem := elem.(type) {
+		case int:
+			s.WriteString(fmt.Sprintf("[%d]", elem))
+		default:
+			if s.Len() > 0 {
+				s.WriteString(".")
+			}
+			s.WriteString(fmt.Sprint(elem))
+		}
+	}
+	if s.Len() == 0 {
+		return "(root)"
+	}
+	return s.String()
+}
+
+// formatValue renders a JSON value on one line, cut to size.
+func formatValue(value interface{}, size int) string {
+	out, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return truncate(string(out), size)
+}
+
+// runStateDiff implements "runsim statediff [-Format text|jsonpatch] [-Modules list] old.json new.json".
+// It compares two exported states or simulation params, e.g. the exports of a
+// seed at two SDK commits, and exits with status 1 if they differ.
+func runStateDiff(args []string) {
+	fs := flag.NewFlagSet("statediff", flag.ExitOnError)
+	format := fs.String("Format", "text", "output format, text for a report by module or jsonpatch for an RFC 6902 patch from the old file to the new one")
+	modules := fs.String("Modules", "", "comma-separated list of the modules to compare, all of them if empty")
+	fs.Usage = func() {
+		_, _ = fmt.Fprintf(fs.Output(), "Usage: %s statediff [-Format text|jsonpatch] [-Modules list] [old-file] [new-file]\n",
+			filepath.Base(os.Args[0]))
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if *format != "text" && *format != "jsonpatch" {
+		log.Fatalf("ERROR: unknown format %q, must be text or jsonpatch", *format)
+	}
+
+	a, err := readJSON(fs.Arg(0))
+	if err != nil {
+		log.Fatalf("ERROR: readJSON: %v", err)
+	}
+	b, err := readJSON(fs.Arg(1))
+	if err != nil {
+		log.Fatalf("ERROR: readJSON: %v", err)
+	}
+
+	var only map[string]bool
+	if *modules != "" {
+		only = make(map[string]bool)
+		for _, module := range strings.Split(*modules, ",") {
+			only[strings.TrimSpace(module)] = true
+		}
+	}
+	var diffs []jsonDifference
+	diffJSON(a, b, nil, func(d jsonDifference) bool {
+		if only == nil || only[diffModule(d.Path)] {
+			diffs = append(diffs, d)
+		}
+		return true
+	})
+
+	if *format == "jsonpatch" {
+		err = writeJSONPatch(os.Stdout, diffs)
+	} else {
+		err = writeDiffReport(os.Stdout, fs.Arg(0), fs.Arg(1), diffs)
+	}
+	if err != nil {
+		log.Fatalf("ERROR: %v", err)
+	}
+	if len(diffs) > 0 {
+		os.Exit(1)
+	}
+}
+
+// diffModule returns the module a path belongs to, i.e. its first key, or the
+// one under app_state for genesis files.
+func diffModule(path []interface{}) string {
+	if len(path) > 1 && path[0] == "app_state" {
+		path = path[1:]
+	}
+	if len(path) == 0 {
+		return ""
+	}
+	return fmt.Sprint(path[0])
+}
+
+// writeDiffReport lists the differences grouped by module, the modules sorted by name.
+func writeDiffReport(w io.Writer, oldFile, newFile string, diffs []jsonDifference) error {
+	var s strings.Builder
+	s.WriteString(fmt.Sprintf("--- %s\n+++ %s\n", oldFile, newFile))
+	if len(diffs) == 0 {
+		s.WriteString("No differences\n")
+		_, err := io.WriteString(w, s.String())
+		return err
+	}
+
+	byModule := make(map[string][]jsonDifference)
+	var modules []string
+	for _, d := range diffs {
+		module := diffModule(d.Path)
+		if _, ok := byModule[module]; !ok {
+			modules = append(modules, module)
+		}
+		byModule[module] = append(byModule[module], d)
+	}
+	sort.Strings(modules)
+
+	for _, module := range modules {
+		name := module
+		if name == "" {
+			name = "(root)"
+		}
+		s.WriteString(fmt.Sprintf("\n%s (%d)\n", name, len(byModule[module])))
+		for _, d := range byModule[module] {
+			switch d.Kind {
+			case diffChanged:
+				s.WriteString(fmt.Sprintf("  ~ %s: %s -> %s\n", formatPath(d.Path),
+					formatValue(d.Old, diffValueSize), formatValue(d.New, diffValueSize)))
+			case diffAdded:
+				s.WriteString(fmt.Sprintf("  + %s: %s\n", formatPath(d.Path), formatValue(d.New, diffValueSize)))
+			case diffRemoved:
+				s.WriteString(fmt.Sprintf("  - %s: %s\n", formatPath(d.Path), formatValue(d.Old, diffValueSize)))
+			}
+		}
+	}
+	s.WriteString(fmt.Sprintf("\n%d differences in %d modules\n", len(diffs), len(modules)))
+	_, err := io.WriteString(w, s.String())
+	return err
+}
+
+type jsonPatchOp struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// writeJSONPatch writes the differences as an RFC 6902 patch turning the old document into the new one.
+func writeJSONPatch(w io.Writer, diffs []jsonDifference) error {
+	ops := make([]jsonPatchOp, 0, len(diffs))
+	for i := 0; i < len(diffs); i++ {
+		d := diffs[i]
+		switch d.Kind {
+		case diffChanged, diffAdded:
+			// a null value must still be written
+			value, err := json.Marshal(d.New)
+			if err != nil {
+				return err
+			}
+			op := "replace"
+			if d.Kind == diffAdded {
+				op = "add"
+			}
+			ops = append(ops, jsonPatchOp{Op: op, Path: jsonPointer(d.Path), Value: value})
+		case diffRemoved:
+			// removing array elements shifts the following ones, remove a truncated array's tail from its end
+			j := i
+			for j+1 < len(diffs) && isArrayTailRemoval(diffs[i], diffs[j+1]) {
+				j++
+			}
+			for k := j; k >= i; k-- {
+				ops = append(ops, jsonPatchOp{Op: "remove", Path: jsonPointer(diffs[k].Path)})
+			}
+			i = j
+		}
+	}
+
+	out, err := json.MarshalIndent(ops, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(out, '\n'))
+	return err
+}
+
+// isArrayTailRemoval reports whether b removes an element of the same array as a.
+func isArrayTailRemoval(a, b jsonDifference) bool {
+	if b.Kind != diffRemoved || len(a.Path) == 0 || len(a.Path) != len(b.Path) {
+		return false
+	}
+	if _, ok := a.Path[len(a.Path)-1].(int); !ok {
+		return false
+	}
+	return reflect.DeepEqual(a.Path[:len(a.Path)-1], b.Path[:len(b.Path)-1])
+}
+
+// jsonPointer renders a path as an RFC 6901 JSON pointer, e.g. /bank/balances/0.
+func jsonPointer(path []interface{}) string {
+	var s strings.Builder
+	for _, elem := range path {
+		s.WriteString("/")
+		s.WriteString(strings.NewReplacer("~", "~0", "/", "~1").Replace(fmt.Sprint(elem)))
+	}
+	return s.String()
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func decodeJSON(t *testing.T, s string) (doc interface{}) {
+	dec := json.NewDecoder(strings.NewReader(s))
+	dec.UseNumber()
+	require.NoError(t, dec.Decode(&doc))
+	return
+}
+
+func TestDiffJSON(t *testing.T) {
+	a := decodeJSON(t, `{"bank":{"supply":"100","params":{}},"staking":{"validators":[{"tokens":1},{"tokens":2}]},"gov":{}}`)
+	b := decodeJSON(t, `{"bank":{"supply":"101","params":{}},"staking":{"validators":[{"tokens":1},{"tokens":3},{"tokens":4}]},"mint":{}}`)
+
+	var diffs []string
+	require.True(t, diffJSON(a, b, nil, func(d jsonDifference) bool {
+		diffs = append(diffs, d.Kind+" "+formatPath(d.Path))
+		return true
+	}))
+	require.Equal(t, []string{
+		"changed bank.supply",
+		"removed gov",
+		"added mint",
+		"changed staking.validators[1].tokens",
+		"added staking.validators[2]",
+	}, diffs)
+
+	var n int
+	require.False(t, diffJSON(a, b, nil, func(jsonDifference) bool { n++; return false }))
+	require.Equal(t, 1, n)
+	require.True(t, diffJSON(a, a, nil, func(jsonDifference) bool { t.Fatal("no difference expected"); return false }))
+}
+
+func TestWriteJSONPatch(t *testing.T) {
+	a := decodeJSON(t, `{"app_state":{"bank":{"balances":[1,2,3,4],"denom/a~b":"stake","params":null}}}`)
+	b := decodeJSON(t, `{"app_state":{"bank":{"balances":[1,5],"denom/a~b":"atom","params":{"x":null}}}}`)
+
+	var diffs []jsonDifference
+	diffJSON(a, b, nil, func(d jsonDifference) bool {
+		diffs = append(diffs, d)
+		return true
+	})
+	for _, d := range diffs {
+		require.Equal(t, "bank", diffModule(d.Path))
+	}
+
+	var out bytes.Buffer
+	require.NoError(t, writeJSONPatch(&out, diffs))
+	require.JSONEq(t, `[
+		{"op": "replace", "path": "/app_state/bank/balances/1", "value": 5},
+		{"op": "remove", "path": "/app_state/bank/balances/3"},
+		{"op": "remove", "path": "/app_state/bank/balances/2"},
+		{"op": "replace", "path": "/app_state/bank/denom~1a~0b", "value": "atom"},
+		{"op": "replace", "path": "/app_state/bank/params", "value": {"x": null}}
+	]`, out.String())
+}
+
+func TestWriteDiffReport(t *testing.T) {
+	a := decodeJSON(t, `{"bank":{"supply":"100"},"gov":{"proposals":[]}}`)
+	b := decodeJSON(t, `{"bank":{"supply":"101"},"mint":{"minter":{}}}`)
+
+	var diffs []jsonDifference
+	diffJSON(a, b, nil, func(d jsonDifference) bool {
+		diffs = append(diffs, d)
+		return true
+	})
+	var out bytes.Buffer
+	require.NoError(t, writeDiffReport(&out, "a.json", "b.json", diffs))
+	require.Equal(t, `--- a.json
++++ b.json
+
+bank (1)
+  ~ bank.supply: "100" -> "101"
+
+gov (1)
+  - gov: {"proposals":[]}
+
+mint (1)
+  + mint: {"minter":{}}
+
+3 differences in 3 modules
+`, out.String())
+
+	out.Reset()
+	require.NoError(t, writeDiffReport(&out, "a.json", "a.json", nil))
+	require.Contains(t, out.String(), "No differences")
+}
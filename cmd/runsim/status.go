@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// upper bounds of the seed duration histogram buckets, simulations take from minutes to a day
+var durationBuckets = []float64{60, 300, 900, 1800, 3600, 7200, 14400, 28800, 57600, 86400}
+
+type runStatus struct {
+	TestName    string          `json:"test_name"`
+	HostId      string          `json:"host_id,omitempty"`
+	Started     time.Time       `json:"started"`
+	Elapsed     float64         `json:"elapsed_seconds"`
+	ETA         string          `json:"eta"`
+	Total       int             `json:"total"`
+	Queued      int             `json:"queued"`
+	Passed      int             `json:"passed"`
+	Failed      int             `json:"failed"`
+	Flaky       int             `json:"flaky"`
+	Interrupted int             `json:"interrupted"`
+	Running     []runningStatus `json:"running"`
+	Completed   []seedReport    `json:"completed"`
+	Pids        []int           `json:"pids"`
+}
+
+type runningStatus struct {
+	Worker  int     `json:"worker"`
+	Seed    int     `json:"seed"`
+	Cell    string  `json:"cell,omitempty"`
+	Pid     int     `json:"pid"`
+	Elapsed float64 `json:"elapsed_seconds"`
+	Stdout  string  `json:"stdout"`
+	Stderr  string  `json:"stderr"`
+}
+
+// serveStatus serves the progress of the run as JSON on /status and as Prometheus metrics on /metrics.
+func serveStatus(addr string, queue chan Seed) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(buildStatus(progress.snapshot(), len(queue))); err != nil {
+			log.Printf("ERROR: status: %v", err)
+		}
+	})
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		if _, err := fmt.Fprint(w, buildMetrics(progress.snapshot(), len(queue))); err != nil {
+			log.Printf("ERROR: metrics: %v", err)
+		}
+	})
+
+	log.Printf("Serving run status on http://%s/status and metrics on http://%s/metrics", addr, addr)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		log.Printf("ERROR: http.ListenAndServe: %v", err)
+	}
+}
+
+func buildStatus(snap progressSnapshot, queued int) runStatus {
+	status := runStatus{
+		TestName:    testname,
+		HostId:      hostId,
+		Started:     snap.Started,
+		Elapsed:     time.Since(snap.Started).Seconds(),
+		ETA:         snap.eta(),
+		Total:       snap.Total,
+		Queued:      queued,
+		Passed:      snap.Passed,
+		Failed:      snap.Failed,
+		Flaky:       snap.Flaky,
+		Interrupted: snap.Interrupted,
+		Running:     []runningStatus{},
+		Completed:   buildReport(snap.Results).Seeds,
+		Pids:        runningPids(),
+	}
+	for id, w := range snap.Workers {
+		if !w.Busy {
+			continue
+		}
+		status.Running = append(status.Running, runningStatus{
+			Worker:  id,
+			Seed:    w.Seed.Num,
+			Cell:    w.Seed.Cell,
+			Pid:     w.Pid,
+			Elapsed: time.Since(w.Started).Seconds(),
+			Stdout:  w.Seed.Stdout,
+			Stderr:  w.Seed.Stderr,
+		})
+	}
+	return status
+}
+
+func runningPids() []int {
+	mutex.Lock()
+	defer mutex.Unlock()
+	pids := make([]int, 0, len(procs))
+	for pid := range procs {
+		pids = append(pids, pid)
+	}
+	sort.Ints(pids)
+	return pids
+}
+
+// buildMetrics renders the run's metrics in the Prometheus text exposition format.
+func buildMetrics(snap progressSnapshot, queued int) string {
+	var metrics strings.Builder
+	write := func(name, kind, help string, value float64) {
+		metrics.WriteString(fmt.Sprintf("# HELP %s %s\n# TYPE %s %s\n%s %g\n", name, help, name, kind, name, value))
+	}
+
+	running := 0
+	for _, w := range snap.Workers {
+		if w.Busy {
+			running++
+		}
+	}
+	write("runsim_seeds", "gauge", "Number of seeds in the run.", float64(snap.Total))
+	write("runsim_seeds_queued", "gauge", "Number of seeds waiting for a worker.", float64(queued))
+	write("runsim_seeds_running", "gauge", "Number of seeds being simulated.", float64(running))
+	write("runsim_seeds_completed", "counter", "Number of seeds that finished.", float64(snap.done()))
+	write("runsim_seeds_failed", "counter", "Number of seeds that failed.", float64(snap.Failed))
+	write("runsim_seeds_flaky", "counter", "Number of seeds that passed on retry.", float64(snap.Flaky))
+
+	name := "runsim_seed_duration_seconds"
+	metrics.WriteString(fmt.Sprintf("# HELP %s Wall time of the completed seeds.\n# TYPE %s histogram\n", name, name))
+	counts := make([]int, len(durationBuckets))
+	var sum float64
+	for _, seed := range snap.Results {
+		seconds := seed.Duration.Seconds()
+		sum += seconds
+		for i, bound := range durationBuckets {
+			if seconds <= bound {
+				counts[i]++
+			}
+		}
+	}
+	for i, bound := range durationBuckets {
+		metrics.WriteString(fmt.Sprintf("%s_bucket{le=\"%g\"} %d\n", name, bound, counts[i]))
+	}
+	metrics.WriteString(fmt.Sprintf("%s_bucket{le=\"+Inf\"} %d\n", name, snap.done()))
+	metrics.WriteString(fmt.Sprintf("%s_sum %g\n%s_count %d\n", name, sum, name, snap.done()))
+	return metrics.String()
+}
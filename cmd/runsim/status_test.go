@@ -0,0 +1,69 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func statusTracker() *progressTracker {
+	tracker := newProgressTracker(3, 6)
+	tracker.seedStarted(0, Seed{Num: 1}, 101)
+	tracker.seedFinished(0, Seed{Num: 1, Duration: 30 * time.Second})
+	tracker.seedStarted(1, Seed{Num: 2}, 102)
+	tracker.seedFinished(1, Seed{Num: 2, Duration: 10 * time.Minute, Failed: true, ExitCode: 1})
+	tracker.seedStarted(0, Seed{Num: 3}, 103)
+	tracker.seedFinished(0, Seed{Num: 3, Duration: 2 * time.Hour, Flaky: true, Attempts: 2})
+	tracker.seedStarted(1, Seed{Num: 4, Stdout: "sim_log-4.stdout"}, 104)
+	tracker.seedStarted(2, Seed{Num: 5, Cell: "mainnet@500"}, 105)
+	return tracker
+}
+
+func TestBuildMetrics(t *testing.T) {
+	metrics := buildMetrics(statusTracker().snapshot(), 1)
+	lines := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSpace(metrics), "\n") {
+		if !strings.HasPrefix(line, "#") {
+			fields := strings.Fields(line)
+			lines[fields[0]] = fields[1]
+		}
+	}
+	require.Contains(t, metrics, "# TYPE runsim_seeds gauge\n")
+	require.Equal(t, "6", lines["runsim_seeds"])
+	require.Equal(t, "1", lines["runsim_seeds_queued"])
+	require.Equal(t, "2", lines["runsim_seeds_running"])
+	require.Equal(t, "3", lines["runsim_seeds_completed"])
+	require.Equal(t, "1", lines["runsim_seeds_failed"])
+	require.Equal(t, "1", lines["runsim_seeds_flaky"])
+
+	// the buckets are cumulative
+	require.Equal(t, "1", lines[`runsim_seed_duration_seconds_bucket{le="60"}`])
+	require.Equal(t, "1", lines[`runsim_seed_duration_seconds_bucket{le="300"}`])
+	require.Equal(t, "2", lines[`runsim_seed_duration_seconds_bucket{le="900"}`])
+	require.Equal(t, "2", lines[`runsim_seed_duration_seconds_bucket{le="3600"}`])
+	require.Equal(t, "3", lines[`runsim_seed_duration_seconds_bucket{le="7200"}`])
+	require.Equal(t, "3", lines[`runsim_seed_duration_seconds_bucket{le="86400"}`])
+	require.Equal(t, "3", lines[`runsim_seed_duration_seconds_bucket{le="+Inf"}`])
+	require.Equal(t, "7830", lines["runsim_seed_duration_seconds_sum"])
+	require.Equal(t, "3", lines["runsim_seed_duration_seconds_count"])
+}
+
+func TestBuildStatus(t *testing.T) {
+	status := buildStatus(statusTracker().snapshot(), 1)
+	require.Equal(t, 6, status.Total)
+	require.Equal(t, 1, status.Queued)
+	require.Equal(t, 1, status.Passed)
+	require.Equal(t, 1, status.Failed)
+	require.Equal(t, 1, status.Flaky)
+	require.Len(t, status.Completed, 3)
+
+	require.Len(t, status.Running, 2)
+	require.Equal(t, 1, status.Running[0].Worker)
+	require.Equal(t, 4, status.Running[0].Seed)
+	require.Equal(t, 104, status.Running[0].Pid)
+	require.Equal(t, "sim_log-4.stdout", status.Running[0].Stdout)
+	require.Equal(t, 2, status.Running[1].Worker)
+	require.Equal(t, "mainnet@500", status.Running[1].Cell)
+}
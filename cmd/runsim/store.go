@@ -0,0 +1,185 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+)
+
+// supported artifact stores
+const (
+	storeS3    = "s3"
+	storeLocal = "local"
+)
+
+const s3MaxRetries = 5
+
+// size of the parts of S3 uploads, a 10000 parts upload can hold up to 640GiB
+var s3PartSize int64 = 64 << 20
+
+// artifactStore is where the compressed logs, exports and the runsim log are published.
+type artifactStore interface {
+	// upload stores the file under key and returns a link to it
+	upload(fileName, key string) (url string, err error)
+}
+
+func validateArtifactStore() error {
+	switch artifactStoreType {
+	case storeS3:
+		return nil
+	case storeLocal:
+		if artifactDir == "" {
+			return fmt.Errorf("-ArtifactDir is required with -ArtifactStore %s", storeLocal)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown artifact store %q, expected %q or %q", artifactStoreType, storeS3, storeLocal)
+}
+
+func newArtifactStore() (artifactStore, error) {
+	if artifactStoreType == storeLocal {
+		return localStore{dir: artifactDir}, nil
+	}
+	return newS3Store()
+}
+
+// s3Store uploads artifacts to the log bucket. Files are streamed in parts so
+// that multi-GB export archives don't have to fit in a single request, each part
+// is retried on its own and carries a Content-MD5 that S3 verifies.
+type s3Store struct {
+	client   *s3.S3
+	uploader *s3manager.Uploader
+	bucket   string
+}
+
+func newS3Store() (store *s3Store, err error) {
+	if s3Endpoint == "" {
+		s3Endpoint = os.Getenv("RUNSIM_S3_ENDPOINT")
+	}
+	config := aws.NewConfig().WithRegion(awsRegion).WithMaxRetries(s3MaxRetries)
+	if s3Endpoint != "" {
+		// S3-compatible stores such as MinIO don't support virtual-hosted buckets
+		config = config.WithEndpoint(s3Endpoint).WithS3ForcePathStyle(true)
+	}
+	sess, err := session.NewSession(config)
+	if err != nil {
+		return
+	}
+
+	store = &s3Store{client: s3.New(sess), bucket: logBucket}
+	store.uploader = s3manager.NewUploaderWithClient(store.client, func(u *s3manager.Uploader) {
+		u.PartSize = s3PartSize
+	})
+	if store.bucket == "" {
+		store.bucket = os.Getenv("RUNSIM_LOG_BUCKET")
+	}
+	if store.bucket == "" {
+		// fall back to the first bucket named after the prefix
+		store.bucket, err = getLogBucket(store.client)
+	}
+	return
+}
+
+func (s *s3Store) upload(fileName, key string) (url string, err error) {
+	checksum, err := sha256File(fileName)
+	if err != nil {
+		return
+	}
+
+	file, err := os.Open(fileName)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	if _, err = s.uploader.Upload(&s3manager.UploadInput{
+		Body:     file,
+		Bucket:   aws.String(s.bucket),
+		Key:      aws.String(key),
+		Metadata: map[string]*string{"sha256": aws.String(checksum)},
+	}); err != nil {
+		return
+	}
+	log.Printf("Uploaded %s to s3://%s/%s (sha256 %s)", fileName, s.bucket, key, checksum)
+	return s.link(key)
+}
+
+// link returns a presigned download URL with -PresignLinks, a plain object URL otherwise.
+func (s *s3Store) link(key string) (string, error) {
+	if presignLinks > 0 {
+		req, _ := s.client.GetObjectRequest(&s3.GetObjectInput{
+			Bucket: aws.String(s.bucket),
+			Key:    aws.String(key),
+		})
+		return req.Presign(presignLinks)
+	}
+	if s3Endpoint != "" {
+		return fmt.Sprintf("%s/%s/%s", strings.TrimSuffix(s3Endpoint, "/"), s.bucket, key), nil
+	}
+	return fmt.Sprintf("https://%s.s3.amazonaws.com/%s", s.bucket, key), nil
+}
+
+func sha256File(fileName string) (string, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// localStore copies artifacts to a directory, e.g. an NFS share reachable by whoever reads the notifications.
+type localStore struct {
+	dir string
+}
+
+func (s localStore) upload(fileName, key string) (url string, err error) {
+	dest, err := filepath.Abs(filepath.Join(s.dir, filepath.FromSlash(key)))
+	if err != nil {
+		return
+	}
+	if err = os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return
+	}
+	if err = copyFile(fileName, dest); err != nil {
+		return
+	}
+	return "file://" + filepath.ToSlash(dest), nil
+}
+
+func copyFile(src, dest string) (err error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return
+	}
+	defer in.Close()
+
+	out, err := os.Create(dest)
+	if err != nil {
+		return
+	}
+	defer func() {
+		cerr := out.Close()
+		if err == nil {
+			err = cerr
+		}
+	}()
+
+	_, err = io.Copy(out, in)
+	return
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyncArtifactsLocal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "runsim-store")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	artifactStoreType, artifactDir = storeLocal, filepath.Join(dir, "share")
+	logObjPrefix, hostId = "nightly", "host-1"
+	defer func() { artifactStoreType, artifactDir, logObjPrefix, hostId = storeS3, "", "", "" }()
+	require.NoError(t, validateArtifactStore())
+
+	okZip := filepath.Join(dir, "ok.zip")
+	require.NoError(t, ioutil.WriteFile(okZip, []byte("logs"), 0644))
+	missing := filepath.Join(dir, "failed.zip")
+
+	objUrls, err := syncArtifacts(okZip, missing)
+	require.NoError(t, err)
+
+	dest := filepath.Join(artifactDir, "nightly", "host-1", "ok.zip")
+	require.Equal(t, map[string]string{okZip: "file://" + dest}, objUrls)
+	content, err := ioutil.ReadFile(dest)
+	require.NoError(t, err)
+	require.Equal(t, "logs", string(content))
+}
+
+func TestValidateArtifactStore(t *testing.T) {
+	defer func() { artifactStoreType, artifactDir = storeS3, "" }()
+
+	artifactStoreType, artifactDir = storeLocal, ""
+	require.Error(t, validateArtifactStore())
+	artifactStoreType = "ftp"
+	require.Error(t, validateArtifactStore())
+	artifactStoreType = storeS3
+	require.NoError(t, validateArtifactStore())
+}
+
+func TestPublishing(t *testing.T) {
+	defer func() { artifactStoreType, logBucket, notifySlack = storeS3, "", false }()
+
+	require.False(t, publishing())
+	artifactStoreType = storeLocal
+	require.True(t, publishing())
+	artifactStoreType, logBucket = storeS3, "sim-logs-nightly"
+	require.True(t, publishing())
+	logBucket, notifySlack = "", true
+	require.True(t, publishing())
+}
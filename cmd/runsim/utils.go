@@ -1,12 +1,11 @@
 package main
 
 import (
-	"archive/zip"
 	"errors"
 	"fmt"
-	"io"
 	"log"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 
@@ -22,8 +21,8 @@ const sqsGithub = "sim-github-"
 const sqsSlack = "sim-slack-"
 
 var (
-	// file paths for the compressed logs
-	okZip, failedZip, exportsZip string
+	// file paths of the archived logs and exports
+	okArchive, failedArchive, interruptedArchive, exportsArchive string
 
 	// integration types and parameters
 	github    = new(runsimgh.Integration)
@@ -31,6 +30,12 @@ var (
 	awsRegion string
 )
 
+// publishing reports whether the results are published: with notifications,
+// or to an artifact store given with -ArtifactStore local or -LogBucket.
+func publishing() bool {
+	return notifyGithub || notifySlack || artifactStoreType == storeLocal || logBucket != ""
+}
+
 func configIntegration() {
 	awsRegion = os.Getenv("AWS_REGION")
 	if awsRegion == "" {
@@ -56,37 +61,50 @@ func configIntegration() {
 	}
 }
 
-func publishResults(okSeeds, failedSeeds, exports []string) {
-	err := compressLogs(okSeeds, failedSeeds, exports)
+// publishResults publishes the archives and the runsim log to the artifact
+// store and sends the notifications. With notifications it exits.
+func publishResults(failed bool, summary string) {
+	archives, err := archiver.Close()
 	if err != nil {
-		pushNotification(true, fmt.Sprintf("Host %s: ERROR: compressLogs: %v\n", hostId, err))
+		log.Printf("ERROR: archiver.Close: %v", err)
+		pushNotification(true, fmt.Sprintf("Host %s: ERROR: archiver.Close: %v\n", hostId, err))
 		os.Exit(1)
 	}
 
-	objUrls, err := syncS3(okZip, failedZip, exportsZip)
+	objUrls, err := syncArtifacts(archives...)
 	if err != nil {
-		pushNotification(true, fmt.Sprintf("Host %s: ERROR: syncS3: %v\n", hostId, err))
+		log.Printf("ERROR: syncArtifacts: %v", err)
+		pushNotification(true, fmt.Sprintf("Host %s: ERROR: syncArtifacts: %v\n", hostId, err))
 		os.Exit(1)
 	}
 
+	if !notifyGithub && !notifySlack {
+		for _, fileName := range archives {
+			log.Printf("Published %s to %s", filepath.Base(fileName), objUrls[fileName])
+		}
+		if _, err := syncArtifacts(runsimLogFile.Name()); err != nil {
+			log.Printf("ERROR: syncArtifacts: %v", err)
+		}
+		return
+	}
+
 	if notifyGithub {
 		if err := github.UpdateActiveCheckRun(); err != nil {
 			log.Printf("ERROR: github.UpdateActiveCheckRun: %v", err)
 		} else {
-			pushNotification(len(failedSeeds) > 0, github.ActiveCheckRun.Output.GetSummary()+buildMessage(objUrls))
+			pushNotification(failed, github.ActiveCheckRun.Output.GetSummary()+buildMessage(objUrls, summary))
 		}
 	} else {
-		pushNotification(len(failedSeeds) > 0, buildMessage(objUrls))
+		pushNotification(failed, buildMessage(objUrls, summary))
 	}
 	uploadLogAndExit()
 }
 
-func syncS3(fileNames ...string) (objUrls map[string]string, err error) {
+// syncArtifacts publishes the existing files among fileNames to the artifact store.
+func syncArtifacts(fileNames ...string) (objUrls map[string]string, err error) {
 	objUrls = make(map[string]string, len(fileNames))
-	sessionS3 := s3.New(session.Must(session.NewSession(&aws.Config{Region: aws.String(awsRegion)})))
-
-	var logBucket string
-	if logBucket, err = getLogBucket(sessionS3); err != nil {
+	store, err := newArtifactStore()
+	if err != nil {
 		return
 	}
 
@@ -94,23 +112,14 @@ func syncS3(fileNames ...string) (objUrls map[string]string, err error) {
 		logObjPrefix = "debug"
 	}
 
-	var file *os.File
 	for _, fileName := range fileNames {
-		_, statErr := os.Stat(fileName)
-		if statErr == nil {
-			if file, err = os.Open(fileName); err != nil {
-				return
-			}
-
-			objKey := filepath.Join(logObjPrefix, hostId, filepath.Base(file.Name()))
-			if _, err = sessionS3.PutObject(&s3.PutObjectInput{
-				Body:   aws.ReadSeekCloser(file),
-				Bucket: aws.String(logBucket),
-				Key:    aws.String(objKey),
-			}); err != nil {
-				return
-			}
-			objUrls[fileName] = fmt.Sprintf("https://%s.s3.amazonaws.com/%s", logBucket, objKey)
+		if _, statErr := os.Stat(fileName); statErr != nil {
+			continue
+		}
+		// object keys always use forward slashes
+		objKey := path.Join(logObjPrefix, hostId, filepath.Base(fileName))
+		if objUrls[fileName], err = store.upload(fileName, objKey); err != nil {
+			return
 		}
 	}
 	return
@@ -131,99 +140,6 @@ func getLogBucket(sessionS3 *s3.S3) (logBucket string, err error) {
 	return logBucket, errors.New("LogBucketNotFound")
 }
 
-func compressLogs(okSeeds, failedSeeds, exportsPaths []string) (err error) {
-	var simExports []string
-	// Export files may not exist if the simulation failed before they are created
-	for _, path := range exportsPaths {
-		_, err := os.Stat(path)
-		if err == nil {
-			simExports = append(simExports, path)
-		}
-	}
-
-	if len(simExports) > 0 {
-		if err = zipFiles(exportsZip, simExports); err != nil {
-			return
-		}
-	}
-
-	if len(okSeeds) > 0 {
-		if err = zipFiles(okZip, okSeeds); err != nil {
-			return
-		}
-	}
-
-	if len(failedSeeds) > 0 {
-		if err = zipFiles(failedZip, failedSeeds); err != nil {
-			return
-		}
-	}
-	return
-}
-
-// compresses one or many files into a single zip archive.
-func zipFiles(filename string, files []string) (err error) {
-	zipFile, err := os.Create(filename)
-	if err != nil {
-		return
-	}
-	defer func() {
-		cerr := zipFile.Close()
-		if err == nil {
-			err = cerr
-		}
-	}()
-
-	zipWriter := zip.NewWriter(zipFile)
-	defer func() {
-		cerr := zipWriter.Close()
-		if err == nil {
-			err = cerr
-		}
-	}()
-
-	for _, file := range files {
-		if err = addFileToZip(zipWriter, file); err != nil {
-			return
-		}
-	}
-	return
-}
-
-func addFileToZip(zipWriter *zip.Writer, fileName string) (err error) {
-	file, err := os.Open(fileName)
-	if err != nil {
-		return err
-	}
-
-	// Get the file information
-	info, err := file.Stat()
-	if err != nil {
-		return
-	}
-
-	header, err := zip.FileInfoHeader(info)
-	if err != nil {
-		return
-	}
-
-	header.Name = file.Name()
-	// change to deflate to gain better compression
-	// see http://golang.org/pkg/archive/zip/#pkg-constants
-	header.Method = zip.Deflate
-	writer, err := zipWriter.CreateHeader(header)
-	if err != nil {
-		return err
-	}
-
-	if _, err = io.Copy(writer, file); err != nil {
-		return
-	}
-
-	err = file.Close()
-	return
-}
-
 func pushNotification(failed bool, message string) {
 	if notifySlack {
 		last, _ := checkIfLast(sqsSlack)
@@ -264,26 +180,32 @@ func pushNotification(failed bool, message string) {
 	}
 }
 
-func buildMessage(objUrls map[string]string) (msg string) {
+func buildMessage(objUrls map[string]string, summary string) (msg string) {
 	var message strings.Builder
 
 	message.WriteString(fmt.Sprintf("Host %s finished simulation. Logs: ", hostId))
 
 	for name, objUrl := range objUrls {
 		switch name {
-		case okZip:
+		case okArchive:
 			if notifySlack {
 				message.WriteString(fmt.Sprintf("<%s|OK> ", objUrl))
 			} else if notifyGithub {
 				message.WriteString(fmt.Sprintf("[OK](%s) ", objUrl))
 			}
-		case failedZip:
+		case failedArchive:
 			if notifySlack {
 				message.WriteString(fmt.Sprintf("*<%s|FAILED>* ", objUrl))
 			} else if notifyGithub {
 				message.WriteString(fmt.Sprintf("[**FAILED**](%s) ", objUrl))
 			}
-		case exportsZip:
+		case interruptedArchive:
+			if notifySlack {
+				message.WriteString(fmt.Sprintf("<%s|INTERRUPTED> ", objUrl))
+			} else if notifyGithub {
+				message.WriteString(fmt.Sprintf("[INTERRUPTED](%s) ", objUrl))
+			}
+		case exportsArchive:
 			if notifySlack {
 				message.WriteString(fmt.Sprintf("<%s|Exports> ", objUrl))
 			} else if notifyGithub {
@@ -293,6 +215,10 @@ func buildMessage(objUrls map[string]string) (msg string) {
 	}
 	// Make it look nice in the github summary
 	message.WriteString("\n")
+	if summary != "" {
+		// both Slack and GitHub render triple backticks as a preformatted block
+		message.WriteString(fmt.Sprintf("```\n%s```\n", summary))
+	}
 	return message.String()
 }
 
@@ -329,9 +255,9 @@ func checkIfLast(queueNamePrefix string) (bool, error) {
 	return true, nil
 }
 
-// Attempt to push the runsim log to S3 before exiting
+// Attempt to push the runsim log to the artifact store before exiting
 func uploadLogAndExit() {
 	_ = runsimLogFile.Close()
-	_, _ = syncS3(runsimLogFile.Name())
+	_, _ = syncArtifacts(runsimLogFile.Name())
 	os.Exit(1)
 }